@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"example.com/e1/internal/app"
+	"example.com/e1/internal/config"
+	"example.com/e1/internal/domain"
+	"example.com/e1/internal/ingest"
+	"example.com/e1/internal/ingest/optionchain"
+	ws "example.com/e1/internal/ingest/websocket"
+)
+
+type subscriptionController interface {
+	Subscribe(subs []config.WebsocketSubscription) error
+	Unsubscribe(subs []config.WebsocketSubscription) error
+	Subscriptions() []config.WebsocketSubscription
+}
+
+func serveControl(ctx context.Context, addr string, handler http.Handler, logger *log.Logger) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Printf("control endpoint listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Printf("control endpoint stopped: %v", err)
+	}
+}
+
+func newControlHandler(controller subscriptionController, stats func() any, token string, logger *log.Logger) http.Handler {
+	mux := http.NewServeMux()
+	if stats != nil {
+		mux.HandleFunc("GET /stats", requireToken(token, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, stats())
+		}))
+	}
+	if controller == nil {
+		return mux
+	}
+	mux.HandleFunc("GET /subscriptions", requireToken(token, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, controller.Subscriptions())
+	}))
+	mux.HandleFunc("POST /subscriptions", requireToken(token, func(w http.ResponseWriter, r *http.Request) {
+		applySubscriptions(w, r, "subscribe", controller.Subscribe, logger)
+	}))
+	mux.HandleFunc("DELETE /subscriptions", requireToken(token, func(w http.ResponseWriter, r *http.Request) {
+		applySubscriptions(w, r, "unsubscribe", controller.Unsubscribe, logger)
+	}))
+	return mux
+}
+
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return next
+	}
+	want := []byte("Bearer " + token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid bearer token"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func applySubscriptions(w http.ResponseWriter, r *http.Request, action string, apply func([]config.WebsocketSubscription) error, logger *log.Logger) {
+	var subs []config.WebsocketSubscription
+	if err := json.NewDecoder(r.Body).Decode(&subs); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("decode subscriptions: %v", err)})
+		return
+	}
+	if err := apply(subs); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if logger != nil {
+		logger.Printf("control: %s %+v", action, subs)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+type websocketStats struct {
+	Disconnects uint64           `json:"disconnects"`
+	Sequence    ws.SequenceStats `json:"sequence"`
+	Stalls      uint64           `json:"stalls"`
+	TokenStalls uint64           `json:"token_stalls"`
+	LastPong    []*time.Time     `json:"last_pong"`
+
+	OptionChains map[string][]string `json:"option_chains,omitempty"`
+}
+
+type pollerStats struct {
+	Stalls      uint64 `json:"stalls"`
+	TokenStalls uint64 `json:"token_stalls"`
+}
+
+type serviceStats struct {
+	Dropped   map[domain.Source]uint64 `json:"dropped"`
+	Websocket *websocketStats          `json:"websocket,omitempty"`
+	Poller    *pollerStats             `json:"poller,omitempty"`
+}
+
+func collectStats(service *app.App, pool *ws.Pool, chains *optionchain.Manager, pollerWatchdog *ingest.Watchdog) serviceStats {
+	stats := serviceStats{Dropped: service.Dropped()}
+	if pool != nil {
+		stats.Websocket = &websocketStats{
+			Disconnects: pool.Disconnects(),
+			Sequence:    pool.SequenceStats(),
+			Stalls:      pool.Stalls(),
+			TokenStalls: pool.TokenStalls(),
+		}
+		for _, pong := range pool.LastPongs() {
+			if pong.IsZero() {
+				stats.Websocket.LastPong = append(stats.Websocket.LastPong, nil)
+				continue
+			}
+			stats.Websocket.LastPong = append(stats.Websocket.LastPong, &pong)
+		}
+		if chains != nil {
+			stats.Websocket.OptionChains = chains.Tokens()
+		}
+	}
+	if pollerWatchdog != nil {
+		stats.Poller = &pollerStats{
+			Stalls:      pollerWatchdog.Stalls(),
+			TokenStalls: pollerWatchdog.TokenStalls(),
+		}
+	}
+	return stats
+}
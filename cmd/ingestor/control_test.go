@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.com/e1/internal/config"
+)
+
+type fakeController struct {
+	subscribed   []config.WebsocketSubscription
+	unsubscribed []config.WebsocketSubscription
+}
+
+func (f *fakeController) Subscribe(subs []config.WebsocketSubscription) error {
+	f.subscribed = append(f.subscribed, subs...)
+	return nil
+}
+
+func (f *fakeController) Unsubscribe(subs []config.WebsocketSubscription) error {
+	f.unsubscribed = append(f.unsubscribed, subs...)
+	return nil
+}
+
+func (f *fakeController) Subscriptions() []config.WebsocketSubscription {
+	return f.subscribed
+}
+
+func TestControlHandlerSubscribesAndUnsubscribes(t *testing.T) {
+	controller := &fakeController{}
+	handler := newControlHandler(controller, nil, "", log.New(io.Discard, "", 0))
+
+	body := `[{"exchange_type":2,"tokens":["43500"]}]`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("POST status = %d", rec.Code)
+	}
+	if len(controller.subscribed) != 1 || controller.subscribed[0].Tokens[0] != "43500" {
+		t.Fatalf("unexpected subscribe calls: %+v", controller.subscribed)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/subscriptions", strings.NewReader(body)))
+	if rec.Code != http.StatusNoContent || len(controller.unsubscribed) != 1 {
+		t.Fatalf("DELETE status = %d, calls = %+v", rec.Code, controller.unsubscribed)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/subscriptions", nil))
+	var listed []config.WebsocketSubscription
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil || len(listed) != 1 {
+		t.Fatalf("GET returned %v, err = %v", listed, err)
+	}
+}
+
+func TestControlHandlerRejectsBadBody(t *testing.T) {
+	handler := newControlHandler(&fakeController{}, nil, "", log.New(io.Discard, "", 0))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader("not json")))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, got %d", rec.Code)
+	}
+}
+
+func TestControlHandlerServesStats(t *testing.T) {
+	handler := newControlHandler(nil, func() any { return map[string]uint64{"stalls": 2} }, "", log.New(io.Discard, "", 0))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	var stats map[string]uint64
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil || stats["stalls"] != 2 {
+		t.Fatalf("GET /stats returned %v, err = %v", stats, err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/subscriptions", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected subscriptions to be unavailable without websocket, got %d", rec.Code)
+	}
+}
+
+func TestControlHandlerRequiresBearerToken(t *testing.T) {
+	controller := &fakeController{}
+	handler := newControlHandler(controller, func() any { return map[string]uint64{} }, "secret", log.New(io.Discard, "", 0))
+	body := `[{"exchange_type":2,"tokens":["43500"]}]`
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized || len(controller.subscribed) != 0 {
+		t.Fatalf("expected unauthorized POST, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/subscriptions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized DELETE, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || len(controller.subscribed) != 1 {
+		t.Fatalf("expected authorized POST, got %d", rec.Code)
+	}
+
+	for _, path := range []string{"/subscriptions", "/stats"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected unauthorized GET %s, got %d", path, rec.Code)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected authorized GET %s, got %d", path, rec.Code)
+		}
+	}
+}
@@ -2,20 +2,29 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"example.com/e1/internal/app"
 	"example.com/e1/internal/auth"
 	"example.com/e1/internal/config"
+	"example.com/e1/internal/ingest"
+	"example.com/e1/internal/ingest/optionchain"
 	"example.com/e1/internal/ingest/poller"
+	"example.com/e1/internal/ingest/replay"
 	ws "example.com/e1/internal/ingest/websocket"
+	"example.com/e1/internal/instruments"
 	"example.com/e1/internal/storage/postgres"
 )
 
 func main() {
+	logout := flag.Bool("logout", false, "clear the cached session and exit")
+	flag.Parse()
+
 	logger := log.New(os.Stdout, "ingestor ", log.LstdFlags|log.Lmicroseconds|log.LUTC)
 
 	cfg, err := config.Load()
@@ -23,13 +32,6 @@ func main() {
 		logger.Fatalf("load config: %v", err)
 	}
 
-	sessionClient := auth.NewClient(cfg.APIKey, cfg.ClientID, cfg.MPIN, cfg.TOTPSecret)
-	sessionClient.SetLoginURL(cfg.LoginURL)
-	session, err := sessionClient.Login(context.Background())
-	if err != nil {
-		logger.Fatalf("login: %v", err)
-	}
-
 	store, err := postgres.NewStore(cfg.DBURL)
 	if err != nil {
 		logger.Fatalf("create store: %v", err)
@@ -40,27 +42,216 @@ func main() {
 		logger.Fatalf("init schema: %v", err)
 	}
 
-	ingestors := make([]app.Ingestor, 0, 2)
+	sessionClient := auth.NewClient(cfg.APIKey, cfg.ClientID, cfg.MPIN, cfg.TOTPSecret)
+	sessionClient.SetLoginURL(cfg.LoginURL)
+	sessionClient.SetRefreshURL(cfg.RefreshURL)
+	sessionClient.SetLogoutURL(cfg.LogoutURL)
+	session := auth.NewProvider(sessionClient, logger)
+	switch cfg.SessionCache {
+	case "file":
+		cache, err := auth.NewFileCache(cfg.SessionCacheFile, cfg.SessionCacheKey)
+		if err != nil {
+			logger.Fatalf("session cache: %v", err)
+		}
+		session.SetCache(cache)
+	case "postgres":
+		sessionCipher, err := auth.NewSessionCipher(cfg.SessionCacheKey)
+		if err != nil {
+			logger.Fatalf("session cache: %v", err)
+		}
+		session.SetCache(store.Sessions(sessionCipher))
+	}
+	if *logout {
+		if err := session.Logout(context.Background()); err != nil {
+			logger.Fatalf("logout: %v", err)
+		}
+		logger.Printf("cached session cleared")
+		return
+	}
+	if cfg.NeedsLogin() {
+		if _, err := session.Login(context.Background()); err != nil {
+			logger.Fatalf("login: %v", err)
+		}
+	}
+
+	enricher := instruments.NewEnricher(nil)
+	syncOpts := instruments.SyncOptions{
+		Fetch: func(ctx context.Context) (*instruments.Master, error) {
+			return loadInstruments(ctx, cfg)
+		},
+		Enricher: enricher,
+		Location: cfg.MarketLocation,
+		At:       cfg.InstrumentsRefreshAt,
+		Logger:   logger,
+	}
+	if cfg.InstrumentsSync {
+		syncOpts.Store = store
+	}
+	syncer := instruments.NewSyncer(syncOpts)
+	if cfg.InstrumentsSync || cfg.HasSymbols() || len(cfg.OptionChains) > 0 {
+		master, err := syncer.Bootstrap(context.Background())
+		switch {
+		case err != nil && (cfg.HasSymbols() || len(cfg.OptionChains) > 0):
+			logger.Fatalf("load instruments: %v", err)
+		case err != nil:
+			logger.Printf("load instruments: %v; ticks will not be enriched until the next sync", err)
+		}
+		if cfg.HasSymbols() {
+			if err := config.ResolveSymbols(&cfg, master); err != nil {
+				logger.Fatalf("load config: %v", err)
+			}
+			logger.Printf("resolved trading symbols against %d instruments", master.Len())
+		}
+	}
+
+	watchdogOpts := ingest.WatchdogOptions{
+		Window: cfg.StaleFeedWindow,
+		MarketHours: ingest.MarketHours{
+			Location: cfg.MarketLocation,
+			Open:     cfg.MarketOpen,
+			Close:    cfg.MarketClose,
+		},
+		Logger: logger,
+	}
+
+	ingestors := make([]app.Ingestor, 0, 3)
+	var wsPool *ws.Pool
+	var chains *optionchain.Manager
+	var capture *ws.CaptureWriter
 	if cfg.EnableWebsocket {
-		ingestors = append(ingestors, ws.New(cfg, session, logger))
+		wsPool = ws.NewPool(cfg, session, logger)
+		if cfg.WebsocketCaptureDir != "" {
+			capture, err = ws.NewCaptureWriter(cfg.WebsocketCaptureDir, cfg.MarketLocation)
+			if err != nil {
+				logger.Fatalf("create frame capture: %v", err)
+			}
+			wsPool.SetCapture(capture)
+		}
+		if cfg.StaleFeedWindow > 0 {
+			wsPool.SetWatchdog(watchdogOpts)
+		}
+		session.OnRefresh(func(auth.Session) {
+			logger.Printf("session renewed, reconnecting websocket with the new tokens")
+			wsPool.Reconnect()
+		})
+		var wsIngestor app.Ingestor = wsPool
+		if len(cfg.OptionChains) > 0 {
+			chains = optionchain.New(wsPool, optionchain.Options{
+				Chains:     cfg.OptionChains,
+				Static:     cfg.WebsocketTokens,
+				Mode:       cfg.WebsocketMode,
+				Master:     enricher.Master,
+				Subscriber: wsPool,
+				Location:   cfg.MarketLocation,
+				Logger:     logger,
+			})
+			wsIngestor = chains
+		}
+		ingestors = append(ingestors, wsIngestor)
 	}
+	var pollerWatchdog *ingest.Watchdog
 	if cfg.EnablePoller {
-		ingestors = append(ingestors, poller.New(cfg, session, logger))
+		var pollerIngestor app.Ingestor = poller.New(cfg, session, logger)
+		if cfg.StaleFeedWindow > 0 {
+			opts := watchdogOpts
+			opts.Name = "poller"
+			opts.Expected = func() []ingest.TokenKey { return pollerTokens(cfg) }
+			pollerWatchdog = ingest.NewWatchdog(pollerIngestor, opts)
+			pollerIngestor = pollerWatchdog
+		}
+		ingestors = append(ingestors, pollerIngestor)
+	}
+	if cfg.EnableReplay {
+		replayOpts := replay.Options{
+			CaptureFiles: cfg.ReplayCaptureFiles,
+			From:         cfg.ReplayFrom,
+			To:           cfg.ReplayTo,
+			Speed:        cfg.ReplaySpeed,
+			Logger:       logger,
+		}
+		if len(cfg.ReplayCaptureFiles) == 0 {
+			replayStore, err := postgres.NewStore(cfg.ReplayDBURL)
+			if err != nil {
+				logger.Fatalf("create replay store: %v", err)
+			}
+			defer replayStore.Close()
+			replayOpts.Ticks = replayStore
+		}
+		ingestors = append(ingestors, replay.New(replayOpts))
+	}
+
+	overflowPolicy, err := app.ParseOverflowPolicy(cfg.QueueOverflowPolicy)
+	if err != nil {
+		logger.Fatalf("QUEUE_OVERFLOW_POLICY: %v", err)
 	}
 
 	service := app.New(app.Options{
-		Logger:        logger,
-		Writer:        store,
-		Ingestors:     ingestors,
-		BatchSize:     cfg.BatchSize,
-		FlushInterval: cfg.FlushInterval,
-		QueueSize:     cfg.QueueSize,
+		Logger:         logger,
+		Writer:         store,
+		Ingestors:      ingestors,
+		BatchSize:      cfg.BatchSize,
+		FlushInterval:  cfg.FlushInterval,
+		FlushTimeout:   cfg.FlushTimeout,
+		QueueSize:      cfg.QueueSize,
+		OverflowPolicy: overflowPolicy,
+		Enricher:       enricher,
 	})
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	if err := service.Run(ctx); err != nil {
-		logger.Fatalf("run service: %v", err)
+	if cfg.InstrumentsSync {
+		go syncer.Run(ctx)
+	}
+
+	if cfg.ControlAddr != "" {
+		var controller subscriptionController
+		if wsPool != nil {
+			controller = wsPool
+		}
+		stats := func() any {
+			return collectStats(service, wsPool, chains, pollerWatchdog)
+		}
+		go serveControl(ctx, cfg.ControlAddr, newControlHandler(controller, stats, cfg.ControlToken, logger), logger)
+	}
+
+	runErr := service.Run(ctx)
+	if capture != nil {
+		if err := capture.Close(); err != nil {
+			logger.Printf("close frame capture: %v", err)
+		}
+	}
+	shutdownSession(cfg, session, logger)
+	if runErr != nil {
+		logger.Fatalf("run service: %v", runErr)
+	}
+}
+
+func shutdownSession(cfg config.Config, session *auth.Provider, logger *log.Logger) {
+	if !cfg.NeedsLogin() || !cfg.LogoutOnShutdown {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := session.Logout(ctx); err != nil {
+		logger.Printf("logout: %v", err)
+		return
+	}
+	logger.Printf("session logged out")
+}
+
+func pollerTokens(cfg config.Config) []ingest.TokenKey {
+	keys := make([]ingest.TokenKey, 0, len(cfg.PollerInstruments))
+	for _, instrument := range cfg.PollerInstruments {
+		exchangeType, _ := instruments.ExchangeType(instrument.Exchange)
+		keys = append(keys, ingest.TokenKey{ExchangeType: exchangeType, Token: instrument.SymbolToken})
+	}
+	return keys
+}
+
+func loadInstruments(ctx context.Context, cfg config.Config) (*instruments.Master, error) {
+	if cfg.InstrumentsFile != "" {
+		return instruments.Load(cfg.InstrumentsFile)
 	}
+	return instruments.Download(ctx, nil, cfg.InstrumentsURL)
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+func encodeFrame(mode, exchangeType int, token string, q quote, now time.Time) []byte {
+	switch mode {
+	case 1:
+		frame := make([]byte, 51)
+		writeHeader(frame, mode, exchangeType, token, q, now)
+		return frame
+	case 2:
+		frame := make([]byte, 123)
+		writeQuote(frame, mode, exchangeType, token, q, now)
+		return frame
+	case 3:
+		frame := make([]byte, 379)
+		writeQuote(frame, mode, exchangeType, token, q, now)
+		writeSnapQuote(frame, exchangeType, q)
+		return frame
+	case 4:
+		return encodeDepth20(exchangeType, token, q, now)
+	default:
+		return nil
+	}
+}
+
+func writeHeader(frame []byte, mode, exchangeType int, token string, q quote, now time.Time) {
+	divisor := priceDivisor(exchangeType)
+	frame[0] = byte(mode)
+	frame[1] = byte(exchangeType)
+	copy(frame[2:27], token)
+	binary.LittleEndian.PutUint64(frame[27:35], uint64(q.Sequence))
+	binary.LittleEndian.PutUint64(frame[35:43], uint64(now.UnixMilli()))
+	binary.LittleEndian.PutUint64(frame[43:51], uint64(scale(q.LTP, divisor)))
+}
+
+func writeQuote(frame []byte, mode, exchangeType int, token string, q quote, now time.Time) {
+	divisor := priceDivisor(exchangeType)
+	writeHeader(frame, mode, exchangeType, token, q, now)
+	binary.LittleEndian.PutUint64(frame[51:59], uint64(q.LastTradedQty))
+	binary.LittleEndian.PutUint64(frame[59:67], uint64(scale(q.AvgPrice, divisor)))
+	binary.LittleEndian.PutUint64(frame[67:75], uint64(q.Volume))
+	binary.LittleEndian.PutUint64(frame[75:83], math.Float64bits(q.TotalBuyQty))
+	binary.LittleEndian.PutUint64(frame[83:91], math.Float64bits(q.TotalSellQty))
+	binary.LittleEndian.PutUint64(frame[91:99], uint64(scale(q.Open, divisor)))
+	binary.LittleEndian.PutUint64(frame[99:107], uint64(scale(q.High, divisor)))
+	binary.LittleEndian.PutUint64(frame[107:115], uint64(scale(q.Low, divisor)))
+	binary.LittleEndian.PutUint64(frame[115:123], uint64(scale(q.Close, divisor)))
+}
+
+func writeSnapQuote(frame []byte, exchangeType int, q quote) {
+	divisor := priceDivisor(exchangeType)
+	binary.LittleEndian.PutUint64(frame[123:131], uint64(q.TradedAt.Unix()))
+	binary.LittleEndian.PutUint64(frame[131:139], uint64(q.OpenInterest))
+	binary.LittleEndian.PutUint64(frame[139:147], math.Float64bits(0))
+	for i := 0; i < 10; i++ {
+		packet := frame[147+i*20 : 147+(i+1)*20]
+		level := float64(i%5 + 1)
+		price := q.LTP - level*0.05
+		if i < 5 {
+			binary.LittleEndian.PutUint16(packet[0:2], 1)
+		} else {
+			price = q.LTP + level*0.05
+		}
+		binary.LittleEndian.PutUint64(packet[2:10], uint64(100*level))
+		binary.LittleEndian.PutUint64(packet[10:18], uint64(scale(price, divisor)))
+		binary.LittleEndian.PutUint16(packet[18:20], uint16(level))
+	}
+	binary.LittleEndian.PutUint64(frame[347:355], uint64(scale(q.Close*1.2, divisor)))
+	binary.LittleEndian.PutUint64(frame[355:363], uint64(scale(q.Close*0.8, divisor)))
+	binary.LittleEndian.PutUint64(frame[363:371], uint64(scale(q.High*1.3, divisor)))
+	binary.LittleEndian.PutUint64(frame[371:379], uint64(scale(q.Low*0.7, divisor)))
+}
+
+func encodeDepth20(exchangeType int, token string, q quote, now time.Time) []byte {
+	divisor := priceDivisor(exchangeType)
+	frame := make([]byte, 443)
+	frame[0] = 4
+	frame[1] = byte(exchangeType)
+	copy(frame[2:27], token)
+	binary.LittleEndian.PutUint64(frame[35:43], uint64(now.UnixMilli()))
+	for i := 0; i < 20; i++ {
+		level := float64(i + 1)
+		bid := frame[43+i*10 : 43+(i+1)*10]
+		binary.LittleEndian.PutUint32(bid[0:4], uint32(50*level))
+		binary.LittleEndian.PutUint32(bid[4:8], uint32(scale(q.LTP-level*0.05, divisor)))
+		binary.LittleEndian.PutUint16(bid[8:10], uint16(level))
+		ask := frame[243+i*10 : 243+(i+1)*10]
+		binary.LittleEndian.PutUint32(ask[0:4], uint32(50*level))
+		binary.LittleEndian.PutUint32(ask[4:8], uint32(scale(q.LTP+level*0.05, divisor)))
+		binary.LittleEndian.PutUint16(ask[8:10], uint16(level))
+	}
+	return frame
+}
+
+func priceDivisor(exchangeType int) float64 {
+	if exchangeType == 13 {
+		return 10000000.0
+	}
+	return 100.0
+}
+
+func scale(price, divisor float64) int64 {
+	return int64(math.Round(price * divisor))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9090", "listen address")
+	tickInterval := flag.Duration("tick", 250*time.Millisecond, "interval between websocket frames per token")
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "mockangel ", log.LstdFlags|log.Lmicroseconds|log.LUTC)
+	srv := newServer(*tickInterval, logger)
+
+	logger.Printf("listening on %s", *addr)
+	logger.Printf("LOGIN_URL=http://%s%s", *addr, loginPath)
+	logger.Printf("QUOTE_URL=http://%s%s", *addr, quotePath)
+	logger.Printf("WEBSOCKET_URL=ws://%s%s", *addr, smartStream)
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           srv.handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	if err := server.ListenAndServe(); err != nil {
+		logger.Fatalf("serve: %v", err)
+	}
+}
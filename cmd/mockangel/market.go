@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type quote struct {
+	LTP           float64
+	Open          float64
+	High          float64
+	Low           float64
+	Close         float64
+	AvgPrice      float64
+	Volume        int64
+	LastTradedQty int64
+	TotalBuyQty   float64
+	TotalSellQty  float64
+	OpenInterest  int64
+	Sequence      int64
+	TradedAt      time.Time
+}
+
+type market struct {
+	mu     sync.Mutex
+	quotes map[string]*quote
+	rng    *rand.Rand
+}
+
+func newMarket(seed uint64) *market {
+	return &market{
+		quotes: make(map[string]*quote),
+		rng:    rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+	}
+}
+
+func (m *market) step(token string, now time.Time) quote {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.quotes[token]
+	if !ok {
+		price := m.startPrice(token)
+		q = &quote{
+			LTP:          price,
+			Open:         price,
+			High:         price,
+			Low:          price,
+			Close:        price,
+			AvgPrice:     price,
+			OpenInterest: int64(m.rng.IntN(500000)),
+		}
+		m.quotes[token] = q
+	}
+
+	q.LTP = roundTick(math.Max(0.05, q.LTP*(1+m.rng.NormFloat64()*0.0005)))
+	q.High = math.Max(q.High, q.LTP)
+	q.Low = math.Min(q.Low, q.LTP)
+	q.LastTradedQty = int64(1 + m.rng.IntN(500))
+	q.AvgPrice = roundTick((q.AvgPrice*float64(q.Volume) + q.LTP*float64(q.LastTradedQty)) / float64(q.Volume+q.LastTradedQty))
+	q.Volume += q.LastTradedQty
+	q.TotalBuyQty = float64(10000 + m.rng.IntN(50000))
+	q.TotalSellQty = float64(10000 + m.rng.IntN(50000))
+	q.OpenInterest += int64(m.rng.IntN(201) - 100)
+	q.Sequence++
+	q.TradedAt = now
+	return *q
+}
+
+func (m *market) startPrice(token string) float64 {
+	if n, err := strconv.Atoi(token); err == nil {
+		return float64(100 + n%5000)
+	}
+	return 100 + float64(m.rng.IntN(5000))
+}
+
+func roundTick(price float64) float64 {
+	return math.Round(price*20) / 20
+}
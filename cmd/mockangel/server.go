@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	loginPath     = "/rest/auth/angelbroking/user/v1/loginByPassword"
+	refreshPath   = "/rest/auth/angelbroking/jwt/v1/generateTokens"
+	logoutPath    = "/rest/secure/angelbroking/user/v1/logout"
+	quotePath     = "/rest/secure/angelbroking/market/v1/quote/"
+	smartStream   = "/smart-stream"
+	mockJWT       = "mock-jwt"
+	mockFeedToken = "mock-feed"
+	mockRefresh   = "mock-refresh"
+)
+
+var exchangeTypes = map[string]int{
+	"NSE": 1,
+	"NFO": 2,
+	"BSE": 3,
+	"BFO": 4,
+	"MCX": 5,
+	"NCX": 7,
+	"CDS": 13,
+}
+
+type server struct {
+	market       *market
+	tickInterval time.Duration
+	logger       *log.Logger
+	now          func() time.Time
+	upgrader     websocket.Upgrader
+}
+
+func newServer(tickInterval time.Duration, logger *log.Logger) *server {
+	return &server{
+		market:       newMarket(uint64(time.Now().UnixNano())),
+		tickInterval: tickInterval,
+		logger:       logger,
+		now:          time.Now,
+	}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+loginPath, s.handleLogin)
+	mux.HandleFunc("POST "+refreshPath, s.handleRefresh)
+	mux.HandleFunc("POST "+logoutPath, s.handleLogout)
+	mux.HandleFunc("POST "+quotePath, s.handleQuote)
+	mux.HandleFunc("GET "+smartStream, s.handleStream)
+	return mux
+}
+
+type envelope struct {
+	Status    bool   `json:"status"`
+	Message   string `json:"message"`
+	ErrorCode string `json:"errorcode"`
+	Data      any    `json:"data"`
+}
+
+func (s *server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ClientCode string `json:"clientcode"`
+		Password   string `json:"password"`
+		TOTP       string `json:"totp"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ClientCode == "" || body.Password == "" || body.TOTP == "" {
+		writeJSON(w, http.StatusOK, envelope{Message: "Invalid totp", ErrorCode: "AB1050"})
+		return
+	}
+	s.logf("login for %s", body.ClientCode)
+	writeJSON(w, http.StatusOK, envelope{
+		Status:  true,
+		Message: "SUCCESS",
+		Data: map[string]string{
+			"jwtToken":     mockJWT,
+			"refreshToken": mockRefresh,
+			"feedToken":    mockFeedToken,
+		},
+	})
+}
+
+func (s *server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RefreshToken != mockRefresh {
+		writeJSON(w, http.StatusOK, envelope{Message: "Invalid Token", ErrorCode: "AG8001"})
+		return
+	}
+	s.logf("session refreshed")
+	writeJSON(w, http.StatusOK, envelope{
+		Status:  true,
+		Message: "SUCCESS",
+		Data: map[string]string{
+			"jwtToken":     mockJWT,
+			"refreshToken": mockRefresh,
+			"feedToken":    mockFeedToken,
+		},
+	})
+}
+
+func (s *server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+mockJWT {
+		writeJSON(w, http.StatusUnauthorized, envelope{Message: "Invalid Token", ErrorCode: "AG8001"})
+		return
+	}
+	s.logf("logout")
+	writeJSON(w, http.StatusOK, envelope{Status: true, Message: "SUCCESS", Data: ""})
+}
+
+type quoteItem struct {
+	Exchange      string  `json:"exchange"`
+	TradingSymbol string  `json:"tradingSymbol"`
+	SymbolToken   string  `json:"symbolToken"`
+	LTP           float64 `json:"ltp"`
+	Open          float64 `json:"open,omitempty"`
+	High          float64 `json:"high,omitempty"`
+	Low           float64 `json:"low,omitempty"`
+	Close         float64 `json:"close,omitempty"`
+	LastTradeQty  int64   `json:"lastTradeQty,omitempty"`
+	ExchFeedTime  string  `json:"exchFeedTime,omitempty"`
+	ExchTradeTime string  `json:"exchTradeTime,omitempty"`
+	AvgPrice      float64 `json:"avgPrice,omitempty"`
+	TradeVolume   int64   `json:"tradeVolume,omitempty"`
+	OpenInterest  int64   `json:"opnInterest,omitempty"`
+	LowerCircuit  float64 `json:"lowerCircuit,omitempty"`
+	UpperCircuit  float64 `json:"upperCircuit,omitempty"`
+	TotBuyQuan    float64 `json:"totBuyQuan,omitempty"`
+	TotSellQuan   float64 `json:"totSellQuan,omitempty"`
+	WeekLow52     float64 `json:"52WeekLow,omitempty"`
+	WeekHigh52    float64 `json:"52WeekHigh,omitempty"`
+	Depth         any     `json:"depth,omitempty"`
+}
+
+func (s *server) handleQuote(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+mockJWT {
+		writeJSON(w, http.StatusUnauthorized, envelope{Message: "Invalid Token", ErrorCode: "AG8001"})
+		return
+	}
+	var body struct {
+		Mode           string              `json:"mode"`
+		ExchangeTokens map[string][]string `json:"exchangeTokens"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusOK, envelope{Message: "Invalid request", ErrorCode: "AB2001"})
+		return
+	}
+
+	now := s.now()
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	fetched := []quoteItem{}
+	for exchange, tokens := range body.ExchangeTokens {
+		for _, token := range tokens {
+			q := s.market.step(token, now)
+			item := quoteItem{
+				Exchange:      exchange,
+				TradingSymbol: "MOCK" + token,
+				SymbolToken:   token,
+				LTP:           q.LTP,
+			}
+			if body.Mode == "OHLC" || body.Mode == "FULL" {
+				item.Open, item.High, item.Low, item.Close = q.Open, q.High, q.Low, q.Close
+			}
+			if body.Mode == "FULL" {
+				item.LastTradeQty = q.LastTradedQty
+				item.ExchFeedTime = now.In(ist).Format("02-Jan-2006 15:04:05")
+				item.ExchTradeTime = q.TradedAt.In(ist).Format("02-Jan-2006 15:04:05")
+				item.AvgPrice = q.AvgPrice
+				item.TradeVolume = q.Volume
+				item.OpenInterest = q.OpenInterest
+				item.LowerCircuit = roundTick(q.Close * 0.8)
+				item.UpperCircuit = roundTick(q.Close * 1.2)
+				item.TotBuyQuan = q.TotalBuyQty
+				item.TotSellQuan = q.TotalSellQty
+				item.WeekLow52 = roundTick(q.Low * 0.7)
+				item.WeekHigh52 = roundTick(q.High * 1.3)
+				item.Depth = quoteDepth(q)
+			}
+			fetched = append(fetched, item)
+		}
+	}
+	writeJSON(w, http.StatusOK, envelope{
+		Status:  true,
+		Message: "SUCCESS",
+		Data: map[string]any{
+			"fetched":   fetched,
+			"unfetched": []any{},
+		},
+	})
+}
+
+func quoteDepth(q quote) map[string][]map[string]any {
+	depth := map[string][]map[string]any{}
+	for i := 1; i <= 5; i++ {
+		level := float64(i)
+		depth["buy"] = append(depth["buy"], map[string]any{"price": roundTick(q.LTP - level*0.05), "quantity": 100 * i, "orders": i})
+		depth["sell"] = append(depth["sell"], map[string]any{"price": roundTick(q.LTP + level*0.05), "quantity": 100 * i, "orders": i})
+	}
+	return depth
+}
+
+type streamKey struct {
+	exchangeType int
+	token        string
+}
+
+func (s *server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("x-feed-token") != mockFeedToken {
+		w.Header().Set("x-error-message", "Invalid Feed Token")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var (
+		mu   sync.Mutex
+		subs = make(map[streamKey]int)
+	)
+	write := func(messageType int, payload []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return conn.WriteMessage(messageType, payload)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			messageType, payload, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if messageType != websocket.TextMessage {
+				continue
+			}
+			if string(payload) == "ping" {
+				_ = write(websocket.TextMessage, []byte("pong"))
+				continue
+			}
+			var req struct {
+				CorrelationID string `json:"correlationID"`
+				Action        int    `json:"action"`
+				Params        struct {
+					Mode      int `json:"mode"`
+					TokenList []struct {
+						ExchangeType int      `json:"exchangeType"`
+						Tokens       []string `json:"tokens"`
+					} `json:"tokenList"`
+				} `json:"params"`
+			}
+			if err := json.Unmarshal(payload, &req); err != nil || req.Params.Mode < 1 || req.Params.Mode > 4 {
+				_ = write(websocket.TextMessage, []byte(`{"correlationID":"`+req.CorrelationID+`","errorCode":"E1001","errorMessage":"Invalid Request Payload."}`))
+				continue
+			}
+			mu.Lock()
+			for _, list := range req.Params.TokenList {
+				for _, token := range list.Tokens {
+					key := streamKey{exchangeType: list.ExchangeType, token: token}
+					if req.Action == 1 {
+						subs[key] = req.Params.Mode
+					} else {
+						delete(subs, key)
+					}
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	ticker := time.NewTicker(s.tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			now := s.now()
+			mu.Lock()
+			frames := make([][]byte, 0, len(subs))
+			for key, mode := range subs {
+				frames = append(frames, encodeFrame(mode, key.exchangeType, key.token, s.market.step(key.token, now), now))
+			}
+			mu.Unlock()
+			for _, frame := range frames {
+				if err := write(websocket.BinaryMessage, frame); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *server) logf(format string, args ...any) {
+	if s.logger != nil {
+		s.logger.Printf(format, args...)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/e1/internal/auth"
+	"example.com/e1/internal/config"
+	"example.com/e1/internal/domain"
+	"example.com/e1/internal/ingest/poller"
+	ws "example.com/e1/internal/ingest/websocket"
+)
+
+func TestEncodedFramesParse(t *testing.T) {
+	now := time.Unix(1710000000, 0)
+	q := newMarket(1).step("2885", now)
+	for mode := 1; mode <= 4; mode++ {
+		tick, err := ws.ParseBinaryTick(encodeFrame(mode, 1, "2885", q, now), time.Now)
+		if err != nil {
+			t.Fatalf("mode %d: ParseBinaryTick() error = %v", mode, err)
+		}
+		if tick.Token != "2885" || (mode < 4 && tick.LTP != q.LTP) {
+			t.Fatalf("mode %d: unexpected tick %+v", mode, tick)
+		}
+	}
+}
+
+func TestIngestorsRunAgainstMock(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	srv := httptest.NewServer(newServer(5*time.Millisecond, logger).handler())
+	defer srv.Close()
+
+	authClient := auth.NewClient("key", "client", "1234", "JBSWY3DPEHPK3PXP")
+	authClient.SetLoginURL(srv.URL + loginPath)
+	authClient.SetRefreshURL(srv.URL + refreshPath)
+	session := auth.NewProvider(authClient, logger)
+	if _, err := session.Login(context.Background()); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	cfg := config.Config{
+		WebsocketURL:  "ws" + strings.TrimPrefix(srv.URL, "http") + smartStream,
+		WebsocketMode: config.ModeQuote,
+		WebsocketTokens: []config.WebsocketSubscription{
+			{ExchangeType: 1, Tokens: []string{"2885"}, Mode: config.ModeLTP},
+			{ExchangeType: 2, Tokens: []string{"35001"}, Mode: config.ModeSnapQuote},
+		},
+		WebsocketPingPeriod:       time.Hour,
+		ReconnectMinDelay:         time.Millisecond,
+		ReconnectMaxDelay:         time.Millisecond,
+		WebsocketMaxTokensPerConn: 1000,
+		PollerMode:                "FULL",
+		PollerInstruments:         []config.PollerInstrument{{Exchange: "NSE", SymbolToken: "1594"}},
+		PollInterval:              10 * time.Millisecond,
+		QuoteURL:                  srv.URL + quotePath,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan domain.Tick, 64)
+	go func() { _ = ws.NewPool(cfg, session, logger).Run(ctx, out) }()
+	go func() { _ = poller.New(cfg, session, logger).Run(ctx, out) }()
+
+	seen := make(map[string]domain.Tick)
+	deadline := time.After(3 * time.Second)
+	for len(seen) < 3 {
+		select {
+		case tick := <-out:
+			seen[tick.Token] = tick
+		case <-deadline:
+			t.Fatalf("only received ticks for %v", seen)
+		}
+	}
+	if tick := seen["35001"]; tick.Depth == nil || len(tick.Depth.Bids) != 5 {
+		t.Fatalf("expected SnapQuote depth for 35001, got %+v", tick)
+	}
+	if tick := seen["1594"]; tick.Source != domain.SourcePoller || tick.LTP <= 0 {
+		t.Fatalf("unexpected poller tick: %+v", tick)
+	}
+}
+
+func TestUnauthorizedResponsesAreJSON(t *testing.T) {
+	srv := httptest.NewServer(newServer(time.Second, log.New(io.Discard, "", 0)).handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+quotePath, "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("POST quote error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
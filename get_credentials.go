@@ -15,6 +15,8 @@ import (
 	// "reflect"
 	"strings"
 	"time"
+
+	"example.com/e1/internal/smartapi"
 )
 
 func getCredentials() (string, string, string, string, error) {
@@ -55,15 +57,7 @@ func getCredentials() (string, string, string, string, error) {
 		fmt.Println(err)
 		return "", "", "", "", err
 	}
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-UserType", "USER")
-	req.Header.Add("X-SourceID", "WEB")
-	req.Header.Add("X-ClientLocalIP", "CLIENT_LOCAL_IP")
-	req.Header.Add("X-ClientPublicIP", "CLIENT_PUBLIC_IP")
-	req.Header.Add("X-MACAddress", "MAC_ADDRESS")
-	// Use the real API key from environment
-	req.Header.Add("X-PrivateKey", apikey)
+	smartapi.SetHeaders(req, apikey, "")
 
 	res, err := client.Do(req)
 	if err != nil {
@@ -11,6 +11,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"example.com/e1/internal/smartapi"
 )
 
 type ExchangeType string
@@ -76,16 +78,7 @@ func getCandleData(apikey string, jwtToken string, exchange ExchangeType, symbol
 		return
 	}
 
-	// Add headers
-	req.Header.Add("Authorization", jwtToken)
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-UserType", "USER")
-	req.Header.Add("X-SourceID", "WEB")
-	req.Header.Add("X-ClientLocalIP", "127.0.0.1")      // Replace with actual if needed
-	req.Header.Add("X-ClientPublicIP", "198.168.0.1")   // Replace with actual if needed
-	req.Header.Add("X-MACAddress", "00:0a:95:9d:68:16") // Replace with actual if needed
-	req.Header.Add("X-PrivateKey", apikey)
+	smartapi.SetHeaders(req, apikey, jwtToken)
 
 	res, err := client.Do(req)
 	if err != nil {
@@ -125,16 +118,7 @@ func getHistoricalOIData(apikey string, jwtToken string, exchange ExchangeType,
 		return
 	}
 
-	// Add headers
-	req.Header.Add("Authorization", jwtToken)
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-UserType", "USER")
-	req.Header.Add("X-SourceID", "WEB")
-	req.Header.Add("X-ClientLocalIP", "127.0.0.1")      // Replace with actual if needed
-	req.Header.Add("X-ClientPublicIP", "198.168.0.1")   // Replace with actual if needed
-	req.Header.Add("X-MACAddress", "00:00:00:00:00:00") // Replace with actual if needed
-	req.Header.Add("X-PrivateKey", apikey)
+	smartapi.SetHeaders(req, apikey, jwtToken)
 
 	res, err := client.Do(req)
 	if err != nil {
@@ -14,32 +14,59 @@ import (
 
 type Ingestor = ingest.Ingestor
 
+const defaultFlushTimeout = 30 * time.Second
+
+type Enricher interface {
+	Enrich(tick *domain.Tick)
+}
+
 type Options struct {
-	Logger        *log.Logger
-	Writer        service.BatchWriter
-	Ingestors     []Ingestor
-	BatchSize     int
-	FlushInterval time.Duration
-	QueueSize     int
+	Logger         *log.Logger
+	Writer         service.BatchWriter
+	Ingestors      []Ingestor
+	BatchSize      int
+	FlushInterval  time.Duration
+	FlushTimeout   time.Duration
+	QueueSize      int
+	OverflowPolicy OverflowPolicy
+	Enricher       Enricher
 }
 
 type App struct {
-	logger        *log.Logger
-	writer        service.BatchWriter
-	ingestors     []Ingestor
-	batchSize     int
-	flushInterval time.Duration
-	queueSize     int
+	logger         *log.Logger
+	writer         service.BatchWriter
+	ingestors      []Ingestor
+	batchSize      int
+	flushInterval  time.Duration
+	flushTimeout   time.Duration
+	queueSize      int
+	overflowPolicy OverflowPolicy
+	enricher       Enricher
+
+	mu      sync.Mutex
+	dropped map[domain.Source]uint64
 }
 
 func New(opts Options) *App {
+	policy := opts.OverflowPolicy
+	if policy == "" {
+		policy = OverflowBlock
+	}
+	flushTimeout := opts.FlushTimeout
+	if flushTimeout <= 0 {
+		flushTimeout = defaultFlushTimeout
+	}
 	return &App{
-		logger:        opts.Logger,
-		writer:        opts.Writer,
-		ingestors:     opts.Ingestors,
-		batchSize:     opts.BatchSize,
-		flushInterval: opts.FlushInterval,
-		queueSize:     opts.QueueSize,
+		logger:         opts.Logger,
+		writer:         opts.Writer,
+		ingestors:      opts.Ingestors,
+		batchSize:      opts.BatchSize,
+		flushInterval:  opts.FlushInterval,
+		flushTimeout:   flushTimeout,
+		queueSize:      opts.QueueSize,
+		overflowPolicy: policy,
+		enricher:       opts.Enricher,
+		dropped:        make(map[domain.Source]uint64),
 	}
 }
 
@@ -51,13 +78,19 @@ func (a *App) Run(ctx context.Context) error {
 		return fmt.Errorf("at least one ingestor is required")
 	}
 
-	ticks := make(chan domain.Tick, a.queueSize)
-	batcher := service.NewBatcher(a.writer, a.batchSize, a.flushInterval, a.logger)
+	in := make(chan domain.Tick)
+	ticks := make(chan domain.Tick)
+	stop := make(chan struct{})
+	defer close(stop)
 
+	batchCtx, cancelBatch := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelBatch()
+	batcher := service.NewBatcher(a.writer, a.batchSize, a.flushInterval, a.logger)
 	batcherDone := make(chan error, 1)
 	go func() {
-		batcherDone <- batcher.Run(ctx, ticks)
+		batcherDone <- batcher.Run(batchCtx, ticks)
 	}()
+	go a.dispatch(in, ticks, stop)
 
 	var wg sync.WaitGroup
 	for _, ingestor := range a.ingestors {
@@ -65,11 +98,16 @@ func (a *App) Run(ctx context.Context) error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := ingestor.Run(ctx, ticks); err != nil && a.logger != nil {
+			if err := ingestor.Run(ctx, in); err != nil && a.logger != nil {
 				a.logger.Printf("ingestor stopped with error: %v", err)
 			}
 		}()
 	}
+	ingestorsDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(ingestorsDone)
+	}()
 
 	select {
 	case err := <-batcherDone:
@@ -78,13 +116,83 @@ func (a *App) Run(ctx context.Context) error {
 		}
 		return nil
 	case <-ctx.Done():
+	case <-ingestorsDone:
+		if a.logger != nil {
+			a.logger.Printf("all ingestors finished, flushing remaining ticks")
+		}
 	}
 
-	wg.Wait()
-	close(ticks)
+	deadline := time.AfterFunc(a.flushTimeout, func() {
+		if a.logger != nil {
+			a.logger.Printf("final flush did not finish within %s, cancelling pending writes", a.flushTimeout)
+		}
+		cancelBatch()
+	})
+	defer deadline.Stop()
+
+	<-ingestorsDone
+	close(in)
 
 	if err := <-batcherDone; err != nil {
 		return err
 	}
 	return nil
 }
+
+func (a *App) Dropped() map[domain.Source]uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	dropped := make(map[domain.Source]uint64, len(a.dropped))
+	for source, count := range a.dropped {
+		dropped[source] = count
+	}
+	return dropped
+}
+
+func (a *App) dispatch(in <-chan domain.Tick, out chan<- domain.Tick, stop <-chan struct{}) {
+	defer close(out)
+
+	queue := newTickQueue(a.overflowPolicy, a.queueSize)
+	open := true
+	for open || queue.len() > 0 {
+		recv := in
+		if !open || (a.overflowPolicy == OverflowBlock && queue.full()) {
+			recv = nil
+		}
+		var send chan<- domain.Tick
+		var next domain.Tick
+		if queue.len() > 0 {
+			send = out
+			next = queue.front()
+		}
+
+		select {
+		case tick, ok := <-recv:
+			if !ok {
+				open = false
+				continue
+			}
+			if a.enricher != nil {
+				a.enricher.Enrich(&tick)
+			}
+			if dropped, ok := queue.push(tick); ok {
+				a.recordDrop(dropped)
+			}
+		case send <- next:
+			queue.pop()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (a *App) recordDrop(tick domain.Tick) {
+	a.mu.Lock()
+	a.dropped[tick.Source]++
+	total := a.dropped[tick.Source]
+	a.mu.Unlock()
+
+	if a.logger != nil && (total == 1 || total%1000 == 0) {
+		a.logger.Printf("tick queue full (%s): dropped %d %s ticks so far", a.overflowPolicy, total, tick.Source)
+	}
+}
@@ -22,9 +22,13 @@ func (f fakeIngestor) Run(ctx context.Context, out chan<- domain.Tick) error {
 type fakeWriter struct {
 	mu    sync.Mutex
 	ticks []domain.Tick
+	block chan struct{}
 }
 
 func (w *fakeWriter) WriteBatch(_ context.Context, ticks []domain.Tick) error {
+	if w.block != nil {
+		<-w.block
+	}
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	w.ticks = append(w.ticks, ticks...)
@@ -100,3 +104,162 @@ func TestAppFlushesTicksOnShutdown(t *testing.T) {
 		t.Fatalf("expected flushed tick on shutdown, got %+v", writer.ticks)
 	}
 }
+
+func TestAppReturnsWhenAllIngestorsFinish(t *testing.T) {
+	writer := &fakeWriter{}
+	app := New(Options{
+		Logger: log.New(io.Discard, "", 0),
+		Writer: writer,
+		Ingestors: []Ingestor{
+			fakeIngestor{run: func(ctx context.Context, out chan<- domain.Tick) error {
+				for _, token := range []string{"a", "b", "c"} {
+					out <- domain.Tick{Token: token}
+				}
+				return nil
+			}},
+		},
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		QueueSize:     4,
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- app.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() did not return after the only ingestor finished")
+	}
+	if len(writer.ticks) != 3 {
+		t.Fatalf("expected all ticks flushed, got %+v", writer.ticks)
+	}
+}
+
+type symbolEnricher map[string]string
+
+func (e symbolEnricher) Enrich(tick *domain.Tick) {
+	tick.TradingSymbol = e[tick.Token]
+}
+
+func TestAppEnrichesTicksBeforeWriting(t *testing.T) {
+	writer := &fakeWriter{}
+	app := New(Options{
+		Logger: log.New(io.Discard, "", 0),
+		Writer: writer,
+		Ingestors: []Ingestor{
+			fakeIngestor{run: func(ctx context.Context, out chan<- domain.Tick) error {
+				out <- domain.Tick{Token: "2885"}
+				<-ctx.Done()
+				return nil
+			}},
+		},
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		QueueSize:     4,
+		Enricher:      symbolEnricher{"2885": "RELIANCE-EQ"},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(writer.ticks) != 1 || writer.ticks[0].TradingSymbol != "RELIANCE-EQ" {
+		t.Fatalf("expected enriched tick, got %+v", writer.ticks)
+	}
+}
+
+func TestAppDropsNewestWhenQueueIsFull(t *testing.T) {
+	writer := &fakeWriter{block: make(chan struct{})}
+	sent := make(chan struct{})
+	app := New(Options{
+		Logger: log.New(io.Discard, "", 0),
+		Writer: writer,
+		Ingestors: []Ingestor{
+			fakeIngestor{run: func(ctx context.Context, out chan<- domain.Tick) error {
+				for i := 0; i < 20; i++ {
+					out <- domain.Tick{Source: domain.SourceWebsocket, Token: "a"}
+				}
+				close(sent)
+				<-ctx.Done()
+				return nil
+			}},
+		},
+		BatchSize:      1,
+		FlushInterval:  time.Hour,
+		QueueSize:      2,
+		OverflowPolicy: OverflowDropNewest,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("ingestor blocked on a full queue")
+	}
+	if dropped := app.Dropped()[domain.SourceWebsocket]; dropped == 0 {
+		t.Fatal("expected dropped websocket ticks")
+	}
+
+	close(writer.block)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := uint64(len(writer.ticks)) + app.Dropped()[domain.SourceWebsocket]; got != 20 {
+		t.Fatalf("expected every tick to be written or counted as dropped, got %d", got)
+	}
+}
+
+type hangingWriter struct{}
+
+func (hangingWriter) WriteBatch(ctx context.Context, _ []domain.Tick) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestAppBoundsFinalFlushOnShutdown(t *testing.T) {
+	app := New(Options{
+		Logger: log.New(io.Discard, "", 0),
+		Writer: hangingWriter{},
+		Ingestors: []Ingestor{
+			fakeIngestor{run: func(ctx context.Context, out chan<- domain.Tick) error {
+				out <- domain.Tick{Token: "a"}
+				<-ctx.Done()
+				return nil
+			}},
+		},
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		FlushTimeout:  20 * time.Millisecond,
+		QueueSize:     4,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected the cancelled flush to be reported")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() did not return after the flush timeout")
+	}
+}
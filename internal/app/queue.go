@@ -0,0 +1,131 @@
+package app
+
+import (
+	"container/list"
+	"fmt"
+
+	"example.com/e1/internal/domain"
+)
+
+type OverflowPolicy string
+
+const (
+	OverflowBlock      OverflowPolicy = "block"
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+	OverflowDropNewest OverflowPolicy = "drop-newest"
+	OverflowConflate   OverflowPolicy = "conflate"
+)
+
+func ParseOverflowPolicy(raw string) (OverflowPolicy, error) {
+	switch policy := OverflowPolicy(raw); policy {
+	case "":
+		return OverflowBlock, nil
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest, OverflowConflate:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown overflow policy %q", raw)
+	}
+}
+
+type conflationKey struct {
+	source       domain.Source
+	exchangeType int
+	token        string
+	depthOnly    bool
+}
+
+type tickQueue struct {
+	policy     OverflowPolicy
+	capacity   int
+	items      *list.List
+	latest     map[conflationKey]*list.Element
+	superseded *list.List
+	stale      map[*list.Element]*list.Element
+}
+
+func newTickQueue(policy OverflowPolicy, capacity int) *tickQueue {
+	return &tickQueue{
+		policy:     policy,
+		capacity:   max(capacity, 1),
+		items:      list.New(),
+		latest:     make(map[conflationKey]*list.Element),
+		superseded: list.New(),
+		stale:      make(map[*list.Element]*list.Element),
+	}
+}
+
+func (q *tickQueue) len() int {
+	return q.items.Len()
+}
+
+func (q *tickQueue) full() bool {
+	return q.items.Len() >= q.capacity
+}
+
+func (q *tickQueue) front() domain.Tick {
+	return q.items.Front().Value.(domain.Tick)
+}
+
+func (q *tickQueue) pop() {
+	q.remove(q.items.Front())
+}
+
+func (q *tickQueue) push(tick domain.Tick) (domain.Tick, bool) {
+	var dropped domain.Tick
+	var didDrop bool
+	if q.full() {
+		if q.policy == OverflowConflate {
+			if element, ok := q.latest[keyOf(tick)]; ok {
+				replaced := element.Value.(domain.Tick)
+				element.Value = tick
+				return replaced, true
+			}
+		}
+		switch {
+		case q.policy == OverflowDropNewest:
+			return tick, true
+		case q.policy == OverflowConflate && q.superseded.Len() > 0:
+			element := q.superseded.Front().Value.(*list.Element)
+			dropped = element.Value.(domain.Tick)
+			didDrop = true
+			q.remove(element)
+		default:
+			dropped = q.front()
+			didDrop = true
+			q.pop()
+		}
+	}
+
+	element := q.items.PushBack(tick)
+	if q.policy == OverflowConflate {
+		key := keyOf(tick)
+		if previous, ok := q.latest[key]; ok {
+			q.stale[previous] = q.superseded.PushBack(previous)
+		}
+		q.latest[key] = element
+	}
+	return dropped, didDrop
+}
+
+func (q *tickQueue) remove(element *list.Element) {
+	tick := q.items.Remove(element).(domain.Tick)
+	if q.policy == OverflowConflate {
+		key := keyOf(tick)
+		if q.latest[key] == element {
+			delete(q.latest, key)
+		}
+		if marker, ok := q.stale[element]; ok {
+			q.superseded.Remove(marker)
+			delete(q.stale, element)
+		}
+	}
+}
+
+func keyOf(tick domain.Tick) conflationKey {
+	return conflationKey{
+		source:       tick.Source,
+		exchangeType: tick.ExchangeType,
+		token:        tick.Token,
+		depthOnly:    tick.DepthOnly,
+	}
+}
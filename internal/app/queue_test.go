@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"example.com/e1/internal/domain"
+)
+
+func drain(q *tickQueue) []string {
+	var tokens []string
+	for q.len() > 0 {
+		tokens = append(tokens, q.front().Token)
+		q.pop()
+	}
+	return tokens
+}
+
+func TestTickQueueDropOldest(t *testing.T) {
+	q := newTickQueue(OverflowDropOldest, 2)
+	q.push(domain.Tick{Token: "a"})
+	q.push(domain.Tick{Token: "b"})
+	dropped, ok := q.push(domain.Tick{Token: "c"})
+	if !ok || dropped.Token != "a" {
+		t.Fatalf("expected oldest tick dropped, got %+v %t", dropped, ok)
+	}
+	if got := drain(q); len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Fatalf("unexpected queue contents: %v", got)
+	}
+}
+
+func TestTickQueueDropNewest(t *testing.T) {
+	q := newTickQueue(OverflowDropNewest, 2)
+	q.push(domain.Tick{Token: "a"})
+	q.push(domain.Tick{Token: "b"})
+	dropped, ok := q.push(domain.Tick{Token: "c"})
+	if !ok || dropped.Token != "c" {
+		t.Fatalf("expected newest tick dropped, got %+v %t", dropped, ok)
+	}
+	if got := drain(q); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("unexpected queue contents: %v", got)
+	}
+}
+
+func TestTickQueueConflatesPerTokenOnlyWhenFull(t *testing.T) {
+	q := newTickQueue(OverflowConflate, 3)
+	q.push(domain.Tick{Token: "a", LTP: 1})
+	q.push(domain.Tick{Token: "b", LTP: 1})
+	if _, ok := q.push(domain.Tick{Token: "a", LTP: 2}); ok {
+		t.Fatal("expected no conflation while the queue has room")
+	}
+
+	dropped, ok := q.push(domain.Tick{Token: "a", LTP: 3})
+	if !ok || dropped.LTP != 2 {
+		t.Fatalf("expected newest queued tick for a to be replaced, got %+v %t", dropped, ok)
+	}
+	dropped, ok = q.push(domain.Tick{Token: "c", LTP: 1})
+	if !ok || dropped.Token != "a" || dropped.LTP != 1 {
+		t.Fatalf("expected oldest tick dropped for a new token, got %+v %t", dropped, ok)
+	}
+
+	var got []string
+	for q.len() > 0 {
+		tick := q.front()
+		got = append(got, fmt.Sprintf("%s%.0f", tick.Token, tick.LTP))
+		q.pop()
+	}
+	if strings.Join(got, ",") != "b1,a3,c1" {
+		t.Fatalf("unexpected queue contents: %v", got)
+	}
+
+	q.push(domain.Tick{Token: "b", LTP: 1})
+	q.push(domain.Tick{Token: "a", LTP: 1})
+	q.push(domain.Tick{Token: "a", LTP: 2})
+	dropped, ok = q.push(domain.Tick{Token: "c", LTP: 1})
+	if !ok || dropped.Token != "a" || dropped.LTP != 1 {
+		t.Fatalf("expected the superseded tick for a to be dropped before b's only tick, got %+v %t", dropped, ok)
+	}
+	dropped, ok = q.push(domain.Tick{Token: "d", LTP: 1})
+	if !ok || dropped.Token != "b" {
+		t.Fatalf("expected the front to be dropped once every tick is the latest, got %+v %t", dropped, ok)
+	}
+
+	got = got[:0]
+	for q.len() > 0 {
+		tick := q.front()
+		got = append(got, fmt.Sprintf("%s%.0f", tick.Token, tick.LTP))
+		q.pop()
+	}
+	if strings.Join(got, ",") != "a2,c1,d1" {
+		t.Fatalf("unexpected queue contents: %v", got)
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	if policy, err := ParseOverflowPolicy(""); err != nil || policy != OverflowBlock {
+		t.Fatalf("expected block default, got %q %v", policy, err)
+	}
+	if _, err := ParseOverflowPolicy("drop-everything"); err == nil {
+		t.Fatal("expected unknown policy error")
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type SessionCache interface {
+	Load(ctx context.Context, clientID string) (Session, bool, error)
+	Save(ctx context.Context, session Session) error
+	Delete(ctx context.Context, clientID string) error
+}
+
+type SessionCipher struct {
+	aead cipher.AEAD
+}
+
+func NewSessionCipher(key string) (*SessionCipher, error) {
+	if key == "" {
+		return nil, fmt.Errorf("session cache key is required")
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("create session cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create session cipher: %w", err)
+	}
+	return &SessionCipher{aead: aead}, nil
+}
+
+func (c *SessionCipher) Seal(session Session) ([]byte, error) {
+	plain, err := json.Marshal(session)
+	if err != nil {
+		return nil, fmt.Errorf("encode session: %w", err)
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("generate session nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (c *SessionCipher) Open(sealed []byte) (Session, error) {
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return Session{}, fmt.Errorf("sealed session is truncated")
+	}
+	plain, err := c.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return Session{}, fmt.Errorf("decrypt session: %w", err)
+	}
+	var session Session
+	if err := json.Unmarshal(plain, &session); err != nil {
+		return Session{}, fmt.Errorf("decode session: %w", err)
+	}
+	return session, nil
+}
+
+type FileCache struct {
+	path   string
+	cipher *SessionCipher
+}
+
+func NewFileCache(path, key string) (*FileCache, error) {
+	sessionCipher, err := NewSessionCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &FileCache{path: path, cipher: sessionCipher}, nil
+}
+
+func (c *FileCache) Load(_ context.Context, clientID string) (Session, bool, error) {
+	sealed, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Session{}, false, nil
+	}
+	if err != nil {
+		return Session{}, false, fmt.Errorf("read session cache: %w", err)
+	}
+	session, err := c.cipher.Open(sealed)
+	if err != nil {
+		return Session{}, false, fmt.Errorf("read session cache: %w", err)
+	}
+	if session.ClientID != clientID {
+		return Session{}, false, nil
+	}
+	return session, true, nil
+}
+
+func (c *FileCache) Save(_ context.Context, session Session) error {
+	sealed, err := c.cipher.Seal(session)
+	if err != nil {
+		return fmt.Errorf("write session cache: %w", err)
+	}
+
+	if dir := filepath.Dir(c.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("create session cache directory: %w", err)
+		}
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, sealed, 0o600); err != nil {
+		return fmt.Errorf("write session cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("write session cache: %w", err)
+	}
+	return nil
+}
+
+func (c *FileCache) Delete(_ context.Context, _ string) error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete session cache: %w", err)
+	}
+	return nil
+}
+
+func TokenExpiry(jwt string) time.Time {
+	parts := strings.Split(strings.TrimPrefix(jwt, "Bearer "), ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0).UTC()
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testJWT(exp time.Time) string {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"client","exp":` + strconv.FormatInt(exp.Unix(), 10) + `}`))
+	return "eyJhbGciOiJIUzUxMiJ9." + claims + ".signature"
+}
+
+func TestTokenExpiryReadsExpClaim(t *testing.T) {
+	exp := time.Date(2024, 3, 21, 18, 30, 0, 0, time.UTC)
+	if got := TokenExpiry("Bearer " + testJWT(exp)); !got.Equal(exp) {
+		t.Fatalf("TokenExpiry() = %v, want %v", got, exp)
+	}
+	if got := TokenExpiry("not-a-jwt"); !got.IsZero() {
+		t.Fatalf("TokenExpiry() = %v, want zero", got)
+	}
+}
+
+func TestFileCacheRoundTripIsEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cache")
+	cache, err := NewFileCache(path, "secret")
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	session := Session{
+		APIKey:       "key",
+		ClientID:     "client",
+		JWTToken:     "Bearer jwt",
+		RefreshToken: "refresh",
+		FeedToken:    "feed",
+		ExpiresAt:    time.Date(2024, 3, 21, 18, 30, 0, 0, time.UTC),
+	}
+	if err := cache.Save(context.Background(), session); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if bytes.Contains(raw, []byte("refresh")) || bytes.Contains(raw, []byte("feed")) {
+		t.Fatal("expected session cache to be encrypted")
+	}
+
+	got, ok, err := cache.Load(context.Background(), "client")
+	if err != nil || !ok || got != session {
+		t.Fatalf("Load() = %+v, %v, %v", got, ok, err)
+	}
+	if _, ok, _ := cache.Load(context.Background(), "other"); ok {
+		t.Fatal("expected cache miss for another client")
+	}
+
+	wrongKey, _ := NewFileCache(path, "other-secret")
+	if _, _, err := wrongKey.Load(context.Background(), "client"); err == nil {
+		t.Fatal("expected decrypt error with the wrong key")
+	}
+
+	if err := cache.Delete(context.Background(), "client"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok, err := cache.Load(context.Background(), "client"); ok || err != nil {
+		t.Fatalf("expected empty cache after Delete, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestProviderReusesCachedSession(t *testing.T) {
+	now := time.Date(2024, 3, 21, 4, 0, 0, 0, time.UTC)
+	var logins atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logins.Add(1)
+		_, _ = w.Write([]byte(`{"status":true,"data":{"jwtToken":"` + testJWT(now.Add(12*time.Hour)) + `","refreshToken":"refresh","feedToken":"feed"}}`))
+	}))
+	defer srv.Close()
+
+	cache, err := NewFileCache(filepath.Join(t.TempDir(), "session.cache"), "secret")
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	newProvider := func() *Provider {
+		client := NewClient("key", "client", "1234", "JBSWY3DPEHPK3PXP")
+		client.SetLoginURL(srv.URL)
+		client.SetLogoutURL(srv.URL)
+		provider := NewProvider(client, nil)
+		provider.now = func() time.Time { return now }
+		provider.SetCache(cache)
+		return provider
+	}
+
+	first, err := newProvider().Login(context.Background())
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	second, err := newProvider().Login(context.Background())
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if logins.Load() != 1 || second != first {
+		t.Fatalf("expected cached session reuse, got %d logins", logins.Load())
+	}
+
+	now = now.Add(12 * time.Hour)
+	if _, err := newProvider().Login(context.Background()); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if logins.Load() != 2 {
+		t.Fatalf("expected expired cache to force a login, got %d logins", logins.Load())
+	}
+
+	provider := newProvider()
+	if err := provider.Logout(context.Background()); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, ok, _ := cache.Load(context.Background(), "client"); ok {
+		t.Fatal("expected Logout to clear the cache")
+	}
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	minRefreshInterval  = 5 * time.Second
+	sessionExpiryMargin = 5 * time.Minute
+)
+
+var ErrRefreshUnsupported = errors.New("session cannot be refreshed")
+
+type SessionProvider interface {
+	Current() Session
+	Refresh(ctx context.Context) (Session, error)
+}
+
+type StaticSession Session
+
+func (s StaticSession) Current() Session {
+	return Session(s)
+}
+
+func (s StaticSession) Refresh(context.Context) (Session, error) {
+	return Session(s), ErrRefreshUnsupported
+}
+
+type Provider struct {
+	client *Client
+	cache  SessionCache
+	logger *log.Logger
+	now    func() time.Time
+
+	mu          sync.Mutex
+	session     Session
+	refreshedAt time.Time
+
+	hooksMu   sync.Mutex
+	onLogin   []func(Session)
+	onRefresh []func(Session)
+	onLogout  []func(Session)
+}
+
+func NewProvider(client *Client, logger *log.Logger) *Provider {
+	return &Provider{client: client, logger: logger, now: time.Now}
+}
+
+func (p *Provider) SetCache(cache SessionCache) {
+	p.cache = cache
+}
+
+func (p *Provider) OnLogin(fn func(Session)) {
+	p.hooksMu.Lock()
+	defer p.hooksMu.Unlock()
+	p.onLogin = append(p.onLogin, fn)
+}
+
+func (p *Provider) OnRefresh(fn func(Session)) {
+	p.hooksMu.Lock()
+	defer p.hooksMu.Unlock()
+	p.onRefresh = append(p.onRefresh, fn)
+}
+
+func (p *Provider) OnLogout(fn func(Session)) {
+	p.hooksMu.Lock()
+	defer p.hooksMu.Unlock()
+	p.onLogout = append(p.onLogout, fn)
+}
+
+func (p *Provider) Login(ctx context.Context) (Session, error) {
+	session, err := p.login(ctx)
+	if err != nil {
+		return Session{}, err
+	}
+	p.notify(&p.onLogin, session)
+	return session, nil
+}
+
+func (p *Provider) login(ctx context.Context) (Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if session, ok := p.cached(ctx); ok {
+		p.logf("reusing cached session, valid until %s", session.ExpiresAt.Format(time.RFC3339))
+		p.session = session
+		return session, nil
+	}
+
+	session, err := p.client.Login(ctx)
+	if err != nil {
+		return Session{}, err
+	}
+	p.store(ctx, session)
+	return session, nil
+}
+
+func (p *Provider) Logout(ctx context.Context) error {
+	p.mu.Lock()
+	session := p.session
+	if session.JWTToken == "" {
+		session, _ = p.cached(ctx)
+	}
+	p.session = Session{}
+	p.refreshedAt = time.Time{}
+
+	var errs []error
+	if session.JWTToken != "" {
+		if err := p.client.Logout(ctx, session); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.cache != nil {
+		if err := p.cache.Delete(ctx, p.client.clientID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	p.mu.Unlock()
+
+	if session.JWTToken != "" {
+		p.notify(&p.onLogout, session)
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Provider) Current() Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.session
+}
+
+func (p *Provider) Refresh(ctx context.Context) (Session, error) {
+	session, renewed, err := p.refresh(ctx)
+	if renewed {
+		p.notify(&p.onRefresh, session)
+	}
+	return session, err
+}
+
+func (p *Provider) refresh(ctx context.Context) (Session, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.refreshedAt.IsZero() && p.now().Sub(p.refreshedAt) < minRefreshInterval {
+		return p.session, false, nil
+	}
+
+	session, err := p.client.Refresh(ctx, p.session)
+	if err != nil {
+		p.logf("session refresh failed, logging in again: %v", err)
+		session, err = p.client.Login(ctx)
+		if err != nil {
+			return p.session, false, fmt.Errorf("re-login: %w", err)
+		}
+		p.logf("session renewed by re-login")
+	} else {
+		p.logf("session renewed with refresh token")
+	}
+	p.store(ctx, session)
+	return session, true, nil
+}
+
+func (p *Provider) cached(ctx context.Context) (Session, bool) {
+	if p.cache == nil {
+		return Session{}, false
+	}
+	session, ok, err := p.cache.Load(ctx, p.client.clientID)
+	if err != nil {
+		p.logf("session cache: %v", err)
+		return Session{}, false
+	}
+	if !ok || session.APIKey != p.client.apiKey || session.ExpiresAt.IsZero() {
+		return Session{}, false
+	}
+	if !p.now().Add(sessionExpiryMargin).Before(session.ExpiresAt) {
+		p.logf("cached session expired at %s", session.ExpiresAt.Format(time.RFC3339))
+		return Session{}, false
+	}
+	return session, true
+}
+
+func (p *Provider) store(ctx context.Context, session Session) {
+	p.session = session
+	p.refreshedAt = p.now()
+	if p.cache == nil {
+		return
+	}
+	if err := p.cache.Save(ctx, session); err != nil {
+		p.logf("session cache: %v", err)
+	}
+}
+
+func (p *Provider) notify(hooks *[]func(Session), session Session) {
+	p.hooksMu.Lock()
+	fns := append([]func(Session){}, (*hooks)...)
+	p.hooksMu.Unlock()
+	for _, fn := range fns {
+		fn(session)
+	}
+}
+
+func (p *Provider) logf(format string, args ...any) {
+	if p.logger != nil {
+		p.logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProviderRefreshesWithRefreshToken(t *testing.T) {
+	var logins, refreshes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/login":
+			logins.Add(1)
+			_, _ = w.Write([]byte(`{"status":true,"data":{"jwtToken":"jwt-1","refreshToken":"refresh-1","feedToken":"feed-1"}}`))
+		case "/refresh":
+			refreshes.Add(1)
+			if body["refreshToken"] != "refresh-1" || r.Header.Get("Authorization") != "Bearer jwt-1" {
+				_, _ = w.Write([]byte(`{"status":false,"message":"Invalid Token","errorcode":"AG8001"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":true,"data":{"jwtToken":"jwt-2","refreshToken":"refresh-2"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient("key", "client", "1234", "JBSWY3DPEHPK3PXP")
+	client.SetLoginURL(srv.URL + "/login")
+	client.SetRefreshURL(srv.URL + "/refresh")
+	provider := NewProvider(client, nil)
+	now := time.Unix(1710000000, 0)
+	provider.now = func() time.Time { return now }
+
+	if _, err := provider.Login(context.Background()); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	now = now.Add(time.Minute)
+	session, err := provider.Refresh(context.Background())
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if session.JWTToken != "Bearer jwt-2" || session.RefreshToken != "refresh-2" || session.FeedToken != "feed-1" {
+		t.Fatalf("unexpected refreshed session: %+v", session)
+	}
+	if provider.Current() != session {
+		t.Fatalf("Current() = %+v, want %+v", provider.Current(), session)
+	}
+
+	if _, err := provider.Refresh(context.Background()); err != nil {
+		t.Fatalf("second Refresh() error = %v", err)
+	}
+	if refreshes.Load() != 1 || logins.Load() != 1 {
+		t.Fatalf("expected back-to-back refreshes to collapse, got %d refreshes and %d logins", refreshes.Load(), logins.Load())
+	}
+}
+
+func TestProviderFallsBackToLogin(t *testing.T) {
+	var logins atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			n := logins.Add(1)
+			if n == 1 {
+				_, _ = w.Write([]byte(`{"status":true,"data":{"jwtToken":"jwt-1","refreshToken":"refresh-1","feedToken":"feed-1"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":true,"data":{"jwtToken":"jwt-3","refreshToken":"refresh-3","feedToken":"feed-3"}}`))
+		case "/refresh":
+			_, _ = w.Write([]byte(`{"status":false,"message":"Token Expired","errorcode":"AG8002"}`))
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient("key", "client", "1234", "JBSWY3DPEHPK3PXP")
+	client.SetLoginURL(srv.URL + "/login")
+	client.SetRefreshURL(srv.URL + "/refresh")
+	provider := NewProvider(client, nil)
+	now := time.Unix(1710000000, 0)
+	provider.now = func() time.Time { return now }
+
+	if _, err := provider.Login(context.Background()); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	now = now.Add(time.Minute)
+	session, err := provider.Refresh(context.Background())
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if session.JWTToken != "Bearer jwt-3" || session.FeedToken != "feed-3" || logins.Load() != 2 {
+		t.Fatalf("expected re-login, got %+v after %d logins", session, logins.Load())
+	}
+}
+
+func TestProviderLifecycleHooksAndLogout(t *testing.T) {
+	var logouts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			_, _ = w.Write([]byte(`{"status":true,"data":{"jwtToken":"jwt-1","refreshToken":"refresh-1","feedToken":"feed-1"}}`))
+		case "/refresh":
+			_, _ = w.Write([]byte(`{"status":true,"data":{"jwtToken":"jwt-2","refreshToken":"refresh-2","feedToken":"feed-2"}}`))
+		case "/logout":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if r.Header.Get("Authorization") != "Bearer jwt-2" || body["clientcode"] != "client" {
+				_, _ = w.Write([]byte(`{"status":false,"message":"Invalid Token","errorcode":"AG8001"}`))
+				return
+			}
+			logouts.Add(1)
+			_, _ = w.Write([]byte(`{"status":true,"message":"SUCCESS","data":""}`))
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient("key", "client", "1234", "JBSWY3DPEHPK3PXP")
+	client.SetLoginURL(srv.URL + "/login")
+	client.SetRefreshURL(srv.URL + "/refresh")
+	client.SetLogoutURL(srv.URL + "/logout")
+	provider := NewProvider(client, nil)
+	now := time.Unix(1710000000, 0)
+	provider.now = func() time.Time { return now }
+
+	var events []string
+	provider.OnLogin(func(s Session) { events = append(events, "login "+s.FeedToken) })
+	provider.OnRefresh(func(s Session) { events = append(events, "refresh "+provider.Current().FeedToken) })
+	provider.OnLogout(func(s Session) { events = append(events, "logout "+s.FeedToken) })
+
+	if _, err := provider.Login(context.Background()); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if _, err := provider.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := provider.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if err := provider.Logout(context.Background()); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+
+	want := []string{"login feed-1", "refresh feed-2", "logout feed-2"}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if logouts.Load() != 1 || provider.Current().JWTToken != "" {
+		t.Fatalf("expected one logout and a cleared session, got %d and %+v", logouts.Load(), provider.Current())
+	}
+}
@@ -1,21 +1,23 @@
 package auth
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"example.com/e1/internal/smartapi"
 	"github.com/pquerna/otp/totp"
 )
 
 type Session struct {
-	APIKey    string
-	ClientID  string
-	JWTToken  string
-	FeedToken string
+	APIKey       string
+	ClientID     string
+	JWTToken     string
+	RefreshToken string
+	FeedToken    string
+	ExpiresAt    time.Time
 }
 
 type Client struct {
@@ -24,7 +26,9 @@ type Client struct {
 	mpin       string
 	totpSecret string
 	loginURL   string
-	httpClient *http.Client
+	refreshURL string
+	logoutURL  string
+	api        *smartapi.Client
 }
 
 func NewClient(apiKey, clientID, mpin, totpSecret string) *Client {
@@ -34,7 +38,9 @@ func NewClient(apiKey, clientID, mpin, totpSecret string) *Client {
 		mpin:       mpin,
 		totpSecret: totpSecret,
 		loginURL:   "https://apiconnect.angelone.in/rest/auth/angelbroking/user/v1/loginByPassword",
-		httpClient: &http.Client{Timeout: 15 * time.Second},
+		refreshURL: "https://apiconnect.angelone.in/rest/auth/angelbroking/jwt/v1/generateTokens",
+		logoutURL:  "https://apiconnect.angelone.in/rest/secure/angelbroking/user/v1/logout",
+		api:        smartapi.NewClient(apiKey),
 	}
 }
 
@@ -42,8 +48,16 @@ func (c *Client) SetLoginURL(url string) {
 	c.loginURL = url
 }
 
+func (c *Client) SetRefreshURL(url string) {
+	c.refreshURL = url
+}
+
+func (c *Client) SetLogoutURL(url string) {
+	c.logoutURL = url
+}
+
 func (c *Client) SetHTTPClient(client *http.Client) {
-	c.httpClient = client
+	c.api.SetHTTPClient(client)
 }
 
 func (c *Client) Login(ctx context.Context) (Session, error) {
@@ -58,49 +72,56 @@ func (c *Client) Login(ctx context.Context) (Session, error) {
 		"totp":       totpCode,
 		"state":      "environment_variable",
 	}
-	body, err := json.Marshal(payload)
+	session, err := c.requestTokens(ctx, c.loginURL, "", payload)
 	if err != nil {
-		return Session{}, fmt.Errorf("marshal login payload: %w", err)
+		return Session{}, fmt.Errorf("login failed: %w", err)
 	}
+	return session, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.loginURL, bytes.NewReader(body))
-	if err != nil {
-		return Session{}, fmt.Errorf("create login request: %w", err)
+func (c *Client) Refresh(ctx context.Context, session Session) (Session, error) {
+	if session.RefreshToken == "" {
+		return Session{}, fmt.Errorf("refresh: no refresh token")
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("X-UserType", "USER")
-	req.Header.Set("X-SourceID", "WEB")
-	req.Header.Set("X-ClientLocalIP", "127.0.0.1")
-	req.Header.Set("X-ClientPublicIP", "127.0.0.1")
-	req.Header.Set("X-MACAddress", "00:00:00:00:00:00")
-	req.Header.Set("X-PrivateKey", c.apiKey)
-
-	resp, err := c.httpClient.Do(req)
+	payload := map[string]string{"refreshToken": session.RefreshToken}
+	refreshed, err := c.requestTokens(ctx, c.refreshURL, session.JWTToken, payload)
 	if err != nil {
-		return Session{}, fmt.Errorf("login request: %w", err)
+		return Session{}, fmt.Errorf("refresh failed: %w", err)
+	}
+	if refreshed.FeedToken == "" {
+		refreshed.FeedToken = session.FeedToken
+	}
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = session.RefreshToken
 	}
-	defer resp.Body.Close()
+	return refreshed, nil
+}
 
-	var result struct {
-		Status  bool   `json:"status"`
-		Message string `json:"message"`
-		Data    struct {
-			JWTToken  string `json:"jwtToken"`
-			FeedToken string `json:"feedToken"`
-		} `json:"data"`
+func (c *Client) Logout(ctx context.Context, session Session) error {
+	payload := map[string]string{"clientcode": c.clientID}
+	if err := c.api.Post(ctx, c.logoutURL, session.JWTToken, payload, nil); err != nil {
+		return fmt.Errorf("logout failed: %w", err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return Session{}, fmt.Errorf("decode login response: %w", err)
+	return nil
+}
+
+func (c *Client) requestTokens(ctx context.Context, url, authorization string, payload map[string]string) (Session, error) {
+	var data struct {
+		JWTToken     string `json:"jwtToken"`
+		RefreshToken string `json:"refreshToken"`
+		FeedToken    string `json:"feedToken"`
 	}
-	if !result.Status {
-		return Session{}, fmt.Errorf("login failed: %s", result.Message)
+	if err := c.api.Post(ctx, url, authorization, payload, &data); err != nil {
+		return Session{}, err
 	}
 
+	jwt := "Bearer " + strings.TrimPrefix(data.JWTToken, "Bearer ")
 	return Session{
-		APIKey:    c.apiKey,
-		ClientID:  c.clientID,
-		JWTToken:  "Bearer " + result.Data.JWTToken,
-		FeedToken: result.Data.FeedToken,
+		APIKey:       c.apiKey,
+		ClientID:     c.clientID,
+		JWTToken:     jwt,
+		RefreshToken: data.RefreshToken,
+		FeedToken:    data.FeedToken,
+		ExpiresAt:    TokenExpiry(jwt),
 	}, nil
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -12,36 +13,95 @@ import (
 	"github.com/joho/godotenv"
 )
 
+const (
+	ModeLTP       = 1
+	ModeQuote     = 2
+	ModeSnapQuote = 3
+	ModeDepth20   = 4
+)
+
 type WebsocketSubscription struct {
 	ExchangeType int      `json:"exchange_type"`
 	Tokens       []string `json:"tokens"`
+	Symbols      []string `json:"symbols,omitempty"`
+	Mode         int      `json:"mode,omitempty"`
 }
 
 type PollerInstrument struct {
 	Exchange    string `json:"exchange"`
 	SymbolToken string `json:"symbol_token"`
+	Symbol      string `json:"symbol,omitempty"`
+}
+
+type OptionChain struct {
+	Underlying   string `json:"underlying"`
+	ExchangeType int    `json:"exchange_type"`
+	Token        string `json:"token"`
+	Expiries     int    `json:"expiries,omitempty"`
+	Strikes      int    `json:"strikes,omitempty"`
+	Mode         int    `json:"mode,omitempty"`
 }
 
 type Config struct {
-	DBURL               string
-	APIKey              string
-	ClientID            string
-	MPIN                string
-	TOTPSecret          string
-	EnableWebsocket     bool
-	EnablePoller        bool
-	WebsocketMode       int
-	WebsocketTokens     []WebsocketSubscription
-	PollerMode          string
-	PollerInstruments   []PollerInstrument
-	PollInterval        time.Duration
+	DBURL              string
+	APIKey             string
+	ClientID           string
+	MPIN               string
+	TOTPSecret         string
+	SessionCache       string
+	SessionCacheFile   string
+	SessionCacheKey    string
+	LogoutOnShutdown   bool
+	EnableWebsocket    bool
+	EnablePoller       bool
+	EnableReplay       bool
+	WebsocketMode      int
+	WebsocketTokens    []WebsocketSubscription
+	OptionChains       []OptionChain
+	PollerMode         string
+	PollerInstruments  []PollerInstrument
+	PollInterval       time.Duration
+	PollerUnfetchedMax int
+
+	PollerMaxTokensPerRequest int
+	PollerRatePerSecond       int
+	PollerRatePerMinute       int
+
 	BatchSize           int
 	FlushInterval       time.Duration
+	FlushTimeout        time.Duration
 	QueueSize           int
+	QueueOverflowPolicy string
 	WebsocketURL        string
 	QuoteURL            string
 	LoginURL            string
+	RefreshURL          string
+	InstrumentsURL      string
+	InstrumentsFile     string
+	LogoutURL           string
 	WebsocketPingPeriod time.Duration
+	ReconnectMinDelay   time.Duration
+	ReconnectMaxDelay   time.Duration
+	ControlAddr         string
+	ControlToken        string
+
+	WebsocketMaxTokensPerConn int
+	WebsocketMaxConnections   int
+	WebsocketCaptureDir       string
+
+	StaleFeedWindow time.Duration
+	MarketOpen      time.Duration
+	MarketClose     time.Duration
+	MarketLocation  *time.Location
+
+	InstrumentsSync      bool
+	InstrumentsRefreshAt time.Duration
+
+	ReplayCaptureFiles []string
+	ReplayFrom         time.Time
+	ReplayTo           time.Time
+	ReplaySpeed        float64
+	ReplayDBURL        string
 }
 
 func Load() (Config, error) {
@@ -49,32 +109,80 @@ func Load() (Config, error) {
 		return Config{}, fmt.Errorf("load .env: %w", err)
 	}
 
+	sessionCache := strings.TrimSpace(os.Getenv("SESSION_CACHE"))
 	cfg := Config{
-		APIKey:              os.Getenv("API_KEY"),
-		DBURL:               os.Getenv("DB_URL"),
-		ClientID:            os.Getenv("CLIENT_ID"),
-		MPIN:                os.Getenv("MPIN"),
-		TOTPSecret:          os.Getenv("TOTP_SECRET"),
-		EnableWebsocket:     getEnvBool("ENABLE_WEBSOCKET", true),
-		EnablePoller:        getEnvBool("ENABLE_POLLER", true),
-		WebsocketMode:       getEnvInt("WEBSOCKET_MODE", 2),
-		PollerMode:          getEnvString("POLLER_MODE", "LTP"),
-		PollInterval:        getEnvDuration("POLL_INTERVAL", time.Second),
+		APIKey:             os.Getenv("API_KEY"),
+		DBURL:              os.Getenv("DB_URL"),
+		ClientID:           os.Getenv("CLIENT_ID"),
+		MPIN:               os.Getenv("MPIN"),
+		TOTPSecret:         os.Getenv("TOTP_SECRET"),
+		SessionCache:       sessionCache,
+		SessionCacheFile:   getEnvString("SESSION_CACHE_FILE", ".session.cache"),
+		SessionCacheKey:    os.Getenv("SESSION_CACHE_KEY"),
+		LogoutOnShutdown:   getEnvBool("LOGOUT_ON_SHUTDOWN", sessionCache == ""),
+		EnableWebsocket:    getEnvBool("ENABLE_WEBSOCKET", true),
+		EnablePoller:       getEnvBool("ENABLE_POLLER", true),
+		EnableReplay:       getEnvBool("ENABLE_REPLAY", false),
+		WebsocketMode:      getEnvInt("WEBSOCKET_MODE", ModeQuote),
+		PollerMode:         getEnvString("POLLER_MODE", "LTP"),
+		PollInterval:       getEnvDuration("POLL_INTERVAL", time.Second),
+		PollerUnfetchedMax: getEnvInt("POLLER_UNFETCHED_LIMIT", 10),
+
+		PollerMaxTokensPerRequest: getEnvInt("POLLER_MAX_TOKENS_PER_REQUEST", 50),
+		PollerRatePerSecond:       getEnvInt("POLLER_RATE_PER_SECOND", 10),
+		PollerRatePerMinute:       getEnvInt("POLLER_RATE_PER_MINUTE", 500),
+
 		BatchSize:           getEnvInt("BATCH_SIZE", 500),
 		FlushInterval:       getEnvDuration("FLUSH_INTERVAL", 5*time.Second),
+		FlushTimeout:        getEnvDuration("SHUTDOWN_FLUSH_TIMEOUT", 30*time.Second),
 		QueueSize:           getEnvInt("QUEUE_SIZE", 2048),
+		QueueOverflowPolicy: getEnvString("QUEUE_OVERFLOW_POLICY", "block"),
 		WebsocketURL:        getEnvString("WEBSOCKET_URL", "wss://smartapisocket.angelone.in/smart-stream"),
 		QuoteURL:            getEnvString("QUOTE_URL", "https://apiconnect.angelone.in/rest/secure/angelbroking/market/v1/quote/"),
 		LoginURL:            getEnvString("LOGIN_URL", "https://apiconnect.angelone.in/rest/auth/angelbroking/user/v1/loginByPassword"),
+		RefreshURL:          getEnvString("REFRESH_URL", "https://apiconnect.angelone.in/rest/auth/angelbroking/jwt/v1/generateTokens"),
+		InstrumentsURL:      getEnvString("INSTRUMENTS_URL", "https://margincalculator.angelbroking.com/OpenAPI_File/files/OpenAPIScripMaster.json"),
+		InstrumentsFile:     strings.TrimSpace(os.Getenv("INSTRUMENTS_FILE")),
+		LogoutURL:           getEnvString("LOGOUT_URL", "https://apiconnect.angelone.in/rest/secure/angelbroking/user/v1/logout"),
 		WebsocketPingPeriod: getEnvDuration("WEBSOCKET_PING_PERIOD", 30*time.Second),
+		ReconnectMinDelay:   getEnvDuration("WEBSOCKET_RECONNECT_MIN_DELAY", time.Second),
+		ReconnectMaxDelay:   getEnvDuration("WEBSOCKET_RECONNECT_MAX_DELAY", time.Minute),
+		ControlAddr:         os.Getenv("CONTROL_ADDR"),
+		ControlToken:        os.Getenv("CONTROL_TOKEN"),
+
+		WebsocketMaxTokensPerConn: getEnvInt("WEBSOCKET_MAX_TOKENS_PER_CONNECTION", 1000),
+		WebsocketMaxConnections:   getEnvInt("WEBSOCKET_MAX_CONNECTIONS", 3),
+		WebsocketCaptureDir:       strings.TrimSpace(os.Getenv("WEBSOCKET_CAPTURE_DIR")),
+
+		StaleFeedWindow: getEnvDuration("STALE_FEED_WINDOW", time.Minute),
+		MarketOpen:      getEnvClock("MARKET_OPEN", 9*time.Hour+15*time.Minute),
+		MarketClose:     getEnvClock("MARKET_CLOSE", 15*time.Hour+30*time.Minute),
+		MarketLocation:  time.FixedZone("IST", 5*60*60+30*60),
+
+		InstrumentsSync:      getEnvBool("INSTRUMENTS_SYNC", true),
+		InstrumentsRefreshAt: getEnvClock("INSTRUMENTS_REFRESH_AT", 8*time.Hour),
+
+		ReplayCaptureFiles: getEnvList("REPLAY_CAPTURE_FILES"),
+		ReplaySpeed:        getEnvFloat("REPLAY_SPEED", 1),
+		ReplayDBURL:        os.Getenv("REPLAY_DB_URL"),
 	}
 
 	if err := parseJSONEnv("WEBSOCKET_TOKENS", &cfg.WebsocketTokens); err != nil {
 		return Config{}, err
 	}
+	if err := parseJSONEnv("OPTION_CHAINS", &cfg.OptionChains); err != nil {
+		return Config{}, err
+	}
+	cfg.addOptionChainUnderlyings()
 	if err := parseJSONEnv("POLLER_INSTRUMENTS", &cfg.PollerInstruments); err != nil {
 		return Config{}, err
 	}
+	if err := parseTimeEnv("REPLAY_FROM", &cfg.ReplayFrom); err != nil {
+		return Config{}, err
+	}
+	if err := parseTimeEnv("REPLAY_TO", &cfg.ReplayTo); err != nil {
+		return Config{}, err
+	}
 
 	if err := validate(cfg); err != nil {
 		return Config{}, err
@@ -85,41 +193,242 @@ func Load() (Config, error) {
 
 func validate(cfg Config) error {
 	required := map[string]string{
-		"DB_URL":      cfg.DBURL,
-		"API_KEY":     cfg.APIKey,
-		"CLIENT_ID":   cfg.ClientID,
-		"MPIN":        cfg.MPIN,
-		"TOTP_SECRET": cfg.TOTPSecret,
+		"DB_URL": cfg.DBURL,
+	}
+	if cfg.NeedsLogin() {
+		required["API_KEY"] = cfg.APIKey
+		required["CLIENT_ID"] = cfg.ClientID
+		required["MPIN"] = cfg.MPIN
+		required["TOTP_SECRET"] = cfg.TOTPSecret
 	}
 	for key, value := range required {
 		if strings.TrimSpace(value) == "" {
 			return fmt.Errorf("%s is required", key)
 		}
 	}
-	if !cfg.EnableWebsocket && !cfg.EnablePoller {
+	if !cfg.EnableWebsocket && !cfg.EnablePoller && !cfg.EnableReplay {
 		return fmt.Errorf("at least one ingestor must be enabled")
 	}
+	if cfg.EnableReplay {
+		if len(cfg.ReplayCaptureFiles) == 0 && (cfg.ReplayFrom.IsZero() || cfg.ReplayTo.IsZero()) {
+			return fmt.Errorf("REPLAY_CAPTURE_FILES or REPLAY_FROM and REPLAY_TO are required when replay is enabled")
+		}
+		if len(cfg.ReplayCaptureFiles) == 0 && !cfg.ReplayTo.After(cfg.ReplayFrom) {
+			return fmt.Errorf("REPLAY_TO must be after REPLAY_FROM")
+		}
+		if cfg.ReplaySpeed < 0 {
+			return fmt.Errorf("REPLAY_SPEED must be >= 0")
+		}
+		if cfg.EnableWebsocket || cfg.EnablePoller {
+			return fmt.Errorf("replay writes to DB_URL and cannot run together with websocket or poller ingestion; run it as a separate process against its own database")
+		}
+		if len(cfg.ReplayCaptureFiles) == 0 && cfg.ReplayDBURL == "" {
+			return fmt.Errorf("REPLAY_DB_URL is required when replaying stored ticks")
+		}
+		if len(cfg.ReplayCaptureFiles) == 0 && cfg.ReplayDBURL == cfg.DBURL {
+			return fmt.Errorf("REPLAY_DB_URL must differ from DB_URL, replayed ticks would be written back into the table they are read from")
+		}
+	}
 	if cfg.EnableWebsocket && len(cfg.WebsocketTokens) == 0 {
 		return fmt.Errorf("WEBSOCKET_TOKENS is required when websocket ingestion is enabled")
 	}
+	if !ValidWebsocketMode(cfg.WebsocketMode) {
+		return fmt.Errorf("WEBSOCKET_MODE %d is not a valid mode", cfg.WebsocketMode)
+	}
+	for _, sub := range cfg.WebsocketTokens {
+		if sub.Mode != 0 && !ValidWebsocketMode(sub.Mode) {
+			return fmt.Errorf("WEBSOCKET_TOKENS entry for exchange_type %d has invalid mode %d", sub.ExchangeType, sub.Mode)
+		}
+		mode := sub.Mode
+		if mode == 0 {
+			mode = cfg.WebsocketMode
+		}
+		if len(sub.Tokens) > 0 {
+			if err := ValidateModeExchange(mode, sub.ExchangeType); err != nil {
+				return fmt.Errorf("WEBSOCKET_TOKENS: %w", err)
+			}
+		}
+		for _, symbol := range sub.Symbols {
+			if _, _, err := ParseSymbol(symbol); err != nil {
+				return fmt.Errorf("WEBSOCKET_TOKENS: %w", err)
+			}
+		}
+	}
+	if len(cfg.OptionChains) > 0 && !cfg.EnableWebsocket {
+		return fmt.Errorf("OPTION_CHAINS requires websocket ingestion")
+	}
+	for _, chain := range cfg.OptionChains {
+		if strings.TrimSpace(chain.Underlying) == "" || chain.Token == "" || chain.ExchangeType == 0 {
+			return fmt.Errorf("OPTION_CHAINS entries need underlying, exchange_type and token")
+		}
+		if chain.Expiries < 0 || chain.Strikes < 0 {
+			return fmt.Errorf("OPTION_CHAINS entry for %s has negative expiries or strikes", chain.Underlying)
+		}
+		if chain.Mode != 0 && !ValidWebsocketMode(chain.Mode) {
+			return fmt.Errorf("OPTION_CHAINS entry for %s has invalid mode %d", chain.Underlying, chain.Mode)
+		}
+	}
 	if cfg.EnablePoller && len(cfg.PollerInstruments) == 0 {
 		return fmt.Errorf("POLLER_INSTRUMENTS is required when poller ingestion is enabled")
 	}
+	for _, instrument := range cfg.PollerInstruments {
+		if instrument.Symbol == "" {
+			continue
+		}
+		if _, _, err := ParseSymbol(instrument.Symbol); err != nil {
+			return fmt.Errorf("POLLER_INSTRUMENTS: %w", err)
+		}
+	}
 	if cfg.BatchSize <= 0 {
 		return fmt.Errorf("BATCH_SIZE must be > 0")
 	}
 	if cfg.QueueSize <= 0 {
 		return fmt.Errorf("QUEUE_SIZE must be > 0")
 	}
+	if cfg.FlushTimeout <= 0 {
+		return fmt.Errorf("SHUTDOWN_FLUSH_TIMEOUT must be > 0")
+	}
 	if cfg.FlushInterval <= 0 {
 		return fmt.Errorf("FLUSH_INTERVAL must be > 0")
 	}
 	if cfg.PollInterval <= 0 {
 		return fmt.Errorf("POLL_INTERVAL must be > 0")
 	}
+	if cfg.ControlAddr != "" && cfg.ControlToken == "" && !loopbackAddr(cfg.ControlAddr) {
+		return fmt.Errorf("CONTROL_ADDR %s is not a loopback address; bind it to 127.0.0.1 or set CONTROL_TOKEN", cfg.ControlAddr)
+	}
+	switch cfg.SessionCache {
+	case "":
+	case "file", "postgres":
+		if cfg.SessionCacheKey == "" {
+			return fmt.Errorf("SESSION_CACHE_KEY is required when SESSION_CACHE=%s", cfg.SessionCache)
+		}
+	default:
+		return fmt.Errorf("SESSION_CACHE must be file or postgres, got %q", cfg.SessionCache)
+	}
+	if cfg.PollerMaxTokensPerRequest <= 0 {
+		return fmt.Errorf("POLLER_MAX_TOKENS_PER_REQUEST must be > 0")
+	}
+	if cfg.PollerRatePerSecond < 0 || cfg.PollerRatePerMinute < 0 {
+		return fmt.Errorf("POLLER_RATE_PER_SECOND and POLLER_RATE_PER_MINUTE must be >= 0")
+	}
+	if cfg.PollerUnfetchedMax < 0 {
+		return fmt.Errorf("POLLER_UNFETCHED_LIMIT must be >= 0")
+	}
 	if cfg.WebsocketPingPeriod <= 0 {
 		return fmt.Errorf("WEBSOCKET_PING_PERIOD must be > 0")
 	}
+	if cfg.WebsocketMaxTokensPerConn <= 0 {
+		return fmt.Errorf("WEBSOCKET_MAX_TOKENS_PER_CONNECTION must be > 0")
+	}
+	if cfg.WebsocketMaxConnections <= 0 {
+		return fmt.Errorf("WEBSOCKET_MAX_CONNECTIONS must be > 0")
+	}
+	if cfg.EnableWebsocket {
+		tokens := cfg.subscriptionCount() + cfg.optionChainDemand()
+		if needed := (tokens + cfg.WebsocketMaxTokensPerConn - 1) / cfg.WebsocketMaxTokensPerConn; needed > cfg.WebsocketMaxConnections {
+			return fmt.Errorf("WEBSOCKET_TOKENS and OPTION_CHAINS need up to %d tokens, which needs %d connections at %d tokens each, more than WEBSOCKET_MAX_CONNECTIONS=%d",
+				tokens, needed, cfg.WebsocketMaxTokensPerConn, cfg.WebsocketMaxConnections)
+		}
+	}
+	if cfg.StaleFeedWindow < 0 {
+		return fmt.Errorf("STALE_FEED_WINDOW must be >= 0")
+	}
+	if cfg.MarketClose <= cfg.MarketOpen {
+		return fmt.Errorf("MARKET_CLOSE must be after MARKET_OPEN")
+	}
+	if cfg.ReconnectMinDelay <= 0 {
+		return fmt.Errorf("WEBSOCKET_RECONNECT_MIN_DELAY must be > 0")
+	}
+	if cfg.ReconnectMaxDelay < cfg.ReconnectMinDelay {
+		return fmt.Errorf("WEBSOCKET_RECONNECT_MAX_DELAY must be >= WEBSOCKET_RECONNECT_MIN_DELAY")
+	}
+	return nil
+}
+
+func (c Config) NeedsLogin() bool {
+	return c.EnableWebsocket || c.EnablePoller
+}
+
+func (c *Config) addOptionChainUnderlyings() {
+	for i := range c.OptionChains {
+		chain := &c.OptionChains[i]
+		if chain.Expiries == 0 {
+			chain.Expiries = 1
+		}
+		if chain.Strikes == 0 {
+			chain.Strikes = 5
+		}
+		if chain.Token == "" || c.subscribes(chain.ExchangeType, chain.Token) {
+			continue
+		}
+		c.WebsocketTokens = append(c.WebsocketTokens, WebsocketSubscription{ExchangeType: chain.ExchangeType, Tokens: []string{chain.Token}})
+	}
+}
+
+func (c Config) subscribes(exchangeType int, token string) bool {
+	for _, sub := range c.WebsocketTokens {
+		if sub.ExchangeType != exchangeType {
+			continue
+		}
+		for _, existing := range sub.Tokens {
+			if existing == token {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c Config) subscriptionCount() int {
+	seen := make(map[string]bool)
+	count := 0
+	for _, sub := range c.WebsocketTokens {
+		for _, token := range sub.Tokens {
+			key := fmt.Sprintf("%d:%s", sub.ExchangeType, token)
+			if !seen[key] {
+				seen[key] = true
+				count++
+			}
+		}
+		count += len(sub.Symbols)
+	}
+	return count
+}
+
+func (c Config) optionChainDemand() int {
+	demand := 0
+	for _, chain := range c.OptionChains {
+		demand += chain.Expiries * (2*chain.Strikes + 1) * 2
+	}
+	return demand
+}
+
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func ValidWebsocketMode(mode int) bool {
+	switch mode {
+	case ModeLTP, ModeQuote, ModeSnapQuote, ModeDepth20:
+		return true
+	default:
+		return false
+	}
+}
+
+func ValidateModeExchange(mode, exchangeType int) error {
+	if mode == ModeDepth20 && exchangeType != 1 && exchangeType != 2 {
+		return fmt.Errorf("20-depth mode is only available for NSE exchange types 1 and 2, got %d", exchangeType)
+	}
 	return nil
 }
 
@@ -134,6 +443,41 @@ func parseJSONEnv(name string, target any) error {
 	return nil
 }
 
+func parseTimeEnv(name string, target *time.Time) error {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return nil
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+	*target = value
+	return nil
+}
+
+func getEnvList(name string) []string {
+	var values []string
+	for _, part := range strings.Split(os.Getenv(name), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+func getEnvFloat(name string, fallback float64) float64 {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
 func getEnvString(name, fallback string) string {
 	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
 		return value
@@ -165,6 +509,18 @@ func getEnvBool(name string, fallback bool) bool {
 	return value
 }
 
+func getEnvClock(name string, fallback time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.Parse("15:04", raw)
+	if err != nil {
+		return fallback
+	}
+	return time.Duration(value.Hour())*time.Hour + time.Duration(value.Minute())*time.Minute
+}
+
 func getEnvDuration(name string, fallback time.Duration) time.Duration {
 	raw := strings.TrimSpace(os.Getenv(name))
 	if raw == "" {
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/e1/internal/instruments"
+)
+
+type SymbolLookup interface {
+	BySymbol(exchange, symbol string) (instruments.Instrument, bool)
+}
+
+func ParseSymbol(raw string) (string, string, error) {
+	exchange, symbol, ok := strings.Cut(strings.TrimSpace(raw), ":")
+	exchange = strings.ToUpper(strings.TrimSpace(exchange))
+	symbol = strings.TrimSpace(symbol)
+	if !ok || exchange == "" || symbol == "" {
+		return "", "", fmt.Errorf("symbol %q must look like EXCHANGE:SYMBOL, for example NSE:RELIANCE-EQ", raw)
+	}
+	if _, ok := instruments.ExchangeType(exchange); !ok {
+		return "", "", fmt.Errorf("symbol %q has unknown exchange %s", raw, exchange)
+	}
+	return exchange, symbol, nil
+}
+
+func (c Config) HasSymbols() bool {
+	for _, sub := range c.WebsocketTokens {
+		if len(sub.Symbols) > 0 {
+			return true
+		}
+	}
+	for _, instrument := range c.PollerInstruments {
+		if instrument.Symbol != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func ResolveSymbols(cfg *Config, lookup SymbolLookup) error {
+	var errs []string
+	resolve := func(raw string) (instruments.Instrument, bool) {
+		exchange, symbol, err := ParseSymbol(raw)
+		if err != nil {
+			errs = append(errs, err.Error())
+			return instruments.Instrument{}, false
+		}
+		instrument, ok := lookup.BySymbol(exchange, symbol)
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unknown symbol %s", raw))
+			return instruments.Instrument{}, false
+		}
+		return instrument, true
+	}
+
+	subs := make([]WebsocketSubscription, 0, len(cfg.WebsocketTokens))
+	for _, sub := range cfg.WebsocketTokens {
+		if len(sub.Tokens) > 0 {
+			subs = append(subs, WebsocketSubscription{ExchangeType: sub.ExchangeType, Tokens: sub.Tokens, Mode: sub.Mode})
+		}
+		byExchange := make(map[int]int)
+		for _, raw := range sub.Symbols {
+			instrument, ok := resolve(raw)
+			if !ok {
+				continue
+			}
+			exchangeType, _ := instruments.ExchangeType(instrument.Exchange)
+			index, exists := byExchange[exchangeType]
+			if !exists {
+				index = len(subs)
+				byExchange[exchangeType] = index
+				subs = append(subs, WebsocketSubscription{ExchangeType: exchangeType, Mode: sub.Mode})
+			}
+			subs[index].Tokens = append(subs[index].Tokens, instrument.Token)
+		}
+	}
+
+	pollerInstruments := make([]PollerInstrument, 0, len(cfg.PollerInstruments))
+	for _, instrument := range cfg.PollerInstruments {
+		if instrument.Symbol == "" {
+			pollerInstruments = append(pollerInstruments, instrument)
+			continue
+		}
+		resolved, ok := resolve(instrument.Symbol)
+		if !ok {
+			continue
+		}
+		pollerInstruments = append(pollerInstruments, PollerInstrument{Exchange: resolved.Exchange, SymbolToken: resolved.Token})
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("resolve symbols: %s", strings.Join(errs, "; "))
+	}
+	for _, sub := range subs {
+		mode := sub.Mode
+		if mode == 0 {
+			mode = cfg.WebsocketMode
+		}
+		if err := ValidateModeExchange(mode, sub.ExchangeType); err != nil {
+			return fmt.Errorf("WEBSOCKET_TOKENS: %w", err)
+		}
+	}
+	cfg.WebsocketTokens = subs
+	cfg.PollerInstruments = pollerInstruments
+	return nil
+}
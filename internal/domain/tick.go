@@ -7,6 +7,7 @@ type Source string
 const (
 	SourceWebsocket Source = "websocket"
 	SourcePoller    Source = "poller"
+	SourceReplay    Source = "replay"
 )
 
 type Tick struct {
@@ -14,6 +15,7 @@ type Tick struct {
 	Token          string
 	Exchange       string
 	ExchangeType   int
+	SequenceNumber int64
 	EventTime      time.Time
 	ReceivedAt     time.Time
 	LTP            float64
@@ -30,5 +32,23 @@ type Tick struct {
 	High52Week     float64
 	Low52Week      float64
 	TradingSymbol  string
+	LotSize        int64
+	Expiry         time.Time
 	LastTradedQty  int64
+	LastTradedTime time.Time
+	OpenInterest   int64
+	OIChangePct    float64
+	Depth          *Depth
+	DepthOnly      bool
+}
+
+type DepthLevel struct {
+	Price    float64
+	Quantity int64
+	Orders   int
+}
+
+type Depth struct {
+	Bids []DepthLevel
+	Asks []DepthLevel
 }
@@ -0,0 +1,325 @@
+package optionchain
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"example.com/e1/internal/config"
+	"example.com/e1/internal/domain"
+	"example.com/e1/internal/ingest"
+	"example.com/e1/internal/instruments"
+)
+
+type Subscriber interface {
+	Subscribe(subs []config.WebsocketSubscription) error
+	Unsubscribe(subs []config.WebsocketSubscription) error
+}
+
+type Options struct {
+	Chains     []config.OptionChain
+	Static     []config.WebsocketSubscription
+	Mode       int
+	Master     func() *instruments.Master
+	Subscriber Subscriber
+	Location   *time.Location
+	Logger     *log.Logger
+}
+
+type contract struct {
+	exchangeType int
+	token        string
+}
+
+type window struct {
+	master *instruments.Master
+	atm    float64
+	expiry string
+}
+
+type chain struct {
+	cfg       config.OptionChain
+	spot      float64
+	window    window
+	contracts map[contract]bool
+}
+
+type Manager struct {
+	inner  ingest.Ingestor
+	opts   Options
+	now    func() time.Time
+	static map[contract]bool
+	wake   chan struct{}
+
+	mu     sync.Mutex
+	chains []*chain
+}
+
+func New(inner ingest.Ingestor, opts Options) *Manager {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	m := &Manager{
+		inner:  inner,
+		opts:   opts,
+		now:    time.Now,
+		static: make(map[contract]bool),
+		wake:   make(chan struct{}, 1),
+	}
+	for _, sub := range opts.Static {
+		for _, token := range sub.Tokens {
+			m.static[contract{exchangeType: sub.ExchangeType, token: token}] = true
+		}
+	}
+	for _, cfg := range opts.Chains {
+		m.chains = append(m.chains, &chain{cfg: cfg, contracts: make(map[contract]bool)})
+	}
+	return m
+}
+
+func (m *Manager) Run(ctx context.Context, out chan<- domain.Tick) error {
+	followCtx, stopFollow := context.WithCancel(ctx)
+	following := make(chan struct{})
+	go func() {
+		defer close(following)
+		m.follow(followCtx)
+	}()
+	defer func() {
+		stopFollow()
+		<-following
+	}()
+
+	relay := make(chan domain.Tick)
+	done := make(chan error, 1)
+	go func() {
+		done <- m.inner.Run(ctx, relay)
+		close(relay)
+	}()
+
+	for tick := range relay {
+		m.observe(tick)
+		select {
+		case out <- tick:
+		case <-ctx.Done():
+		}
+	}
+	return <-done
+}
+
+func (m *Manager) Tokens() map[string][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tokens := make(map[string][]string, len(m.chains))
+	for _, c := range m.chains {
+		list := make([]string, 0, len(c.contracts))
+		for key := range c.contracts {
+			list = append(list, key.token)
+		}
+		sort.Strings(list)
+		tokens[c.cfg.Underlying] = list
+	}
+	return tokens
+}
+
+func (m *Manager) observe(tick domain.Tick) {
+	if tick.LTP <= 0 {
+		return
+	}
+	matched := false
+	m.mu.Lock()
+	for _, c := range m.chains {
+		if c.cfg.Token == tick.Token && c.cfg.ExchangeType == tick.ExchangeType {
+			c.spot = tick.LTP
+			matched = true
+		}
+	}
+	m.mu.Unlock()
+	if !matched {
+		return
+	}
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) follow(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+			m.refresh()
+		}
+	}
+}
+
+func (m *Manager) refresh() {
+	for _, c := range m.chains {
+		m.mu.Lock()
+		spot := c.spot
+		m.mu.Unlock()
+		if spot > 0 {
+			m.update(c, spot)
+		}
+	}
+}
+
+func (m *Manager) update(c *chain, spot float64) {
+	if m.opts.Master == nil {
+		return
+	}
+	master := m.opts.Master()
+	if master == nil {
+		return
+	}
+	expiries := m.nearestExpiries(master, c.cfg)
+	if len(expiries) == 0 {
+		return
+	}
+	atm, ok := nearestStrike(master.Strikes(c.cfg.Underlying, expiries[0]), spot)
+	if !ok {
+		return
+	}
+	next := window{master: master, atm: atm, expiry: expiries[0].Format("2006-01-02")}
+	if next == c.window {
+		return
+	}
+
+	wanted := make(map[contract]bool)
+	for _, expiry := range expiries {
+		strikes := master.Strikes(c.cfg.Underlying, expiry)
+		center, ok := nearestIndex(strikes, spot)
+		if !ok {
+			continue
+		}
+		for i := max(center-c.cfg.Strikes, 0); i <= min(center+c.cfg.Strikes, len(strikes)-1); i++ {
+			for _, kind := range []string{"CE", "PE"} {
+				instrument, ok := master.Contract(c.cfg.Underlying, expiry, strikes[i], kind)
+				if !ok {
+					continue
+				}
+				exchangeType, ok := instruments.ExchangeType(instrument.Exchange)
+				if !ok {
+					continue
+				}
+				wanted[contract{exchangeType: exchangeType, token: instrument.Token}] = true
+			}
+		}
+	}
+
+	var added, removed []contract
+	for key := range wanted {
+		if !c.contracts[key] {
+			added = append(added, key)
+		}
+	}
+	for key := range c.contracts {
+		if !wanted[key] {
+			removed = append(removed, key)
+		}
+	}
+
+	mode := c.cfg.Mode
+	if mode == 0 {
+		mode = m.opts.Mode
+	}
+	if owned := m.owned(removed); len(owned) > 0 {
+		if err := m.opts.Subscriber.Unsubscribe(group(owned, 0)); err != nil {
+			m.logf("%s option chain: unsubscribe: %v", c.cfg.Underlying, err)
+			return
+		}
+	}
+	m.mu.Lock()
+	for _, key := range removed {
+		delete(c.contracts, key)
+	}
+	m.mu.Unlock()
+	if owned := m.owned(added); len(owned) > 0 {
+		if err := m.opts.Subscriber.Subscribe(group(owned, mode)); err != nil {
+			m.logf("%s option chain: subscribe: %v", c.cfg.Underlying, err)
+			return
+		}
+	}
+	m.mu.Lock()
+	for _, key := range added {
+		c.contracts[key] = true
+	}
+	m.mu.Unlock()
+	c.window = next
+	m.logf("%s option chain: spot %.2f, ATM %g, %d expiries, +%d -%d contracts",
+		c.cfg.Underlying, spot, atm, len(expiries), len(added), len(removed))
+}
+
+func (m *Manager) owned(contracts []contract) []contract {
+	var owned []contract
+	for _, key := range contracts {
+		if !m.static[key] {
+			owned = append(owned, key)
+		}
+	}
+	return owned
+}
+
+func (m *Manager) nearestExpiries(master *instruments.Master, cfg config.OptionChain) []time.Time {
+	local := m.now().In(m.opts.Location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	var expiries []time.Time
+	for _, expiry := range master.Expiries(cfg.Underlying) {
+		if expiry.Before(today) || len(master.Strikes(cfg.Underlying, expiry)) == 0 {
+			continue
+		}
+		expiries = append(expiries, expiry)
+		if len(expiries) == cfg.Expiries {
+			break
+		}
+	}
+	return expiries
+}
+
+func nearestStrike(strikes []float64, spot float64) (float64, bool) {
+	i, ok := nearestIndex(strikes, spot)
+	if !ok {
+		return 0, false
+	}
+	return strikes[i], true
+}
+
+func nearestIndex(strikes []float64, spot float64) (int, bool) {
+	if len(strikes) == 0 {
+		return 0, false
+	}
+	i := sort.SearchFloat64s(strikes, spot)
+	switch {
+	case i == len(strikes):
+		i--
+	case i > 0 && spot-strikes[i-1] <= strikes[i]-spot:
+		i--
+	}
+	return i, true
+}
+
+func group(contracts []contract, mode int) []config.WebsocketSubscription {
+	sort.Slice(contracts, func(a, b int) bool {
+		if contracts[a].exchangeType != contracts[b].exchangeType {
+			return contracts[a].exchangeType < contracts[b].exchangeType
+		}
+		return contracts[a].token < contracts[b].token
+	})
+	var subs []config.WebsocketSubscription
+	for _, key := range contracts {
+		if len(subs) == 0 || subs[len(subs)-1].ExchangeType != key.exchangeType {
+			subs = append(subs, config.WebsocketSubscription{ExchangeType: key.exchangeType, Mode: mode})
+		}
+		subs[len(subs)-1].Tokens = append(subs[len(subs)-1].Tokens, key.token)
+	}
+	return subs
+}
+
+func (m *Manager) logf(format string, args ...any) {
+	if m.opts.Logger != nil {
+		m.opts.Logger.Printf(format, args...)
+	}
+}
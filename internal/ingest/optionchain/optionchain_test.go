@@ -0,0 +1,225 @@
+package optionchain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"testing"
+	"time"
+
+	"example.com/e1/internal/config"
+	"example.com/e1/internal/domain"
+	"example.com/e1/internal/instruments"
+)
+
+type recordingSubscriber struct {
+	subscribed   [][]config.WebsocketSubscription
+	unsubscribed [][]config.WebsocketSubscription
+}
+
+func (s *recordingSubscriber) Subscribe(subs []config.WebsocketSubscription) error {
+	s.subscribed = append(s.subscribed, subs)
+	return nil
+}
+
+func (s *recordingSubscriber) Unsubscribe(subs []config.WebsocketSubscription) error {
+	s.unsubscribed = append(s.unsubscribed, subs)
+	return nil
+}
+
+func testMaster() *instruments.Master {
+	var list []instruments.Instrument
+	token := 35000
+	for _, expiry := range []time.Time{
+		time.Date(2024, 11, 28, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 12, 5, 0, 0, 0, 0, time.UTC),
+	} {
+		for strike := 24300.0; strike <= 24700; strike += 100 {
+			for _, kind := range []string{"CE", "PE"} {
+				list = append(list, instruments.Instrument{
+					Token:          fmt.Sprint(token),
+					Symbol:         fmt.Sprintf("NIFTY%s%.0f%s", expiry.Format("02Jan06"), strike, kind),
+					Name:           "NIFTY",
+					Expiry:         expiry,
+					Strike:         strike,
+					LotSize:        25,
+					Exchange:       "NFO",
+					InstrumentType: "OPTIDX",
+				})
+				token++
+			}
+		}
+	}
+	return instruments.New(list)
+}
+
+func newTestManager(subscriber *recordingSubscriber, chain config.OptionChain, now time.Time) *Manager {
+	master := testMaster()
+	m := New(nil, Options{
+		Chains:     []config.OptionChain{chain},
+		Mode:       2,
+		Master:     func() *instruments.Master { return master },
+		Subscriber: subscriber,
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	m.now = func() time.Time { return now }
+	return m
+}
+
+func spot(ltp float64) domain.Tick {
+	return domain.Tick{Token: "99926000", ExchangeType: 1, LTP: ltp}
+}
+
+func move(m *Manager, tick domain.Tick) {
+	m.observe(tick)
+	m.refresh()
+}
+
+func tokens(subs []config.WebsocketSubscription) []string {
+	var list []string
+	for _, sub := range subs {
+		list = append(list, sub.Tokens...)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestManagerSubscribesAndMovesStrikeWindow(t *testing.T) {
+	subscriber := &recordingSubscriber{}
+	chain := config.OptionChain{Underlying: "NIFTY", ExchangeType: 1, Token: "99926000", Expiries: 1, Strikes: 1}
+	m := newTestManager(subscriber, chain, time.Date(2024, 11, 20, 10, 0, 0, 0, time.UTC))
+
+	move(m, spot(24480))
+	if len(subscriber.subscribed) != 1 {
+		t.Fatalf("expected one subscribe, got %d", len(subscriber.subscribed))
+	}
+	first := subscriber.subscribed[0]
+	if len(first) != 1 || first[0].ExchangeType != 2 || first[0].Mode != 2 {
+		t.Fatalf("unexpected subscription: %+v", first)
+	}
+	if got := tokens(first); fmt.Sprint(got) != "[35002 35003 35004 35005 35006 35007]" {
+		t.Fatalf("ATM 24500 window = %v", got)
+	}
+
+	move(m, spot(24530))
+	if len(subscriber.subscribed) != 1 || len(subscriber.unsubscribed) != 0 {
+		t.Fatal("expected no change while ATM strike is unchanged")
+	}
+
+	move(m, spot(24620))
+	if len(subscriber.unsubscribed) != 1 || fmt.Sprint(tokens(subscriber.unsubscribed[0])) != "[35002 35003]" {
+		t.Fatalf("unexpected unsubscribe: %+v", subscriber.unsubscribed)
+	}
+	if len(subscriber.subscribed) != 2 || fmt.Sprint(tokens(subscriber.subscribed[1])) != "[35008 35009]" {
+		t.Fatalf("unexpected subscribe: %+v", subscriber.subscribed)
+	}
+	if got := m.Tokens()["NIFTY"]; len(got) != 6 {
+		t.Fatalf("Tokens() = %v", got)
+	}
+}
+
+func TestManagerSkipsExpiredContractsAndClampsWindow(t *testing.T) {
+	subscriber := &recordingSubscriber{}
+	chain := config.OptionChain{Underlying: "NIFTY", ExchangeType: 1, Token: "99926000", Expiries: 2, Strikes: 1, Mode: 1}
+	m := newTestManager(subscriber, chain, time.Date(2024, 11, 29, 10, 0, 0, 0, time.UTC))
+
+	move(m, spot(25000))
+	if len(subscriber.subscribed) != 1 {
+		t.Fatalf("expected one subscribe, got %d", len(subscriber.subscribed))
+	}
+	if sub := subscriber.subscribed[0][0]; sub.Mode != 1 {
+		t.Fatalf("expected chain mode, got %+v", sub)
+	}
+	if got := tokens(subscriber.subscribed[0]); fmt.Sprint(got) != "[35016 35017 35018 35019]" {
+		t.Fatalf("expected the two highest 05DEC strikes, got %v", got)
+	}
+}
+
+func TestManagerKeepsStaticSubscriptions(t *testing.T) {
+	subscriber := &recordingSubscriber{}
+	master := testMaster()
+	m := New(nil, Options{
+		Chains:     []config.OptionChain{{Underlying: "NIFTY", ExchangeType: 1, Token: "99926000", Expiries: 1, Strikes: 1}},
+		Static:     []config.WebsocketSubscription{{ExchangeType: 2, Tokens: []string{"35002", "35008"}, Mode: 3}},
+		Master:     func() *instruments.Master { return master },
+		Subscriber: subscriber,
+	})
+	m.now = func() time.Time { return time.Date(2024, 11, 20, 10, 0, 0, 0, time.UTC) }
+
+	move(m, spot(24480))
+	if got := tokens(subscriber.subscribed[0]); fmt.Sprint(got) != "[35003 35004 35005 35006 35007]" {
+		t.Fatalf("expected static token to be left alone, got %v", got)
+	}
+	move(m, spot(24620))
+	if got := tokens(subscriber.unsubscribed[0]); fmt.Sprint(got) != "[35003]" {
+		t.Fatalf("expected only manager tokens unsubscribed, got %v", got)
+	}
+	if got := tokens(subscriber.subscribed[1]); fmt.Sprint(got) != "[35009]" {
+		t.Fatalf("expected static token not to be resubscribed, got %v", got)
+	}
+	if got := m.Tokens()["NIFTY"]; len(got) != 6 {
+		t.Fatalf("Tokens() = %v", got)
+	}
+}
+
+type signallingSubscriber struct {
+	recordingSubscriber
+	done chan struct{}
+}
+
+func (s *signallingSubscriber) Subscribe(subs []config.WebsocketSubscription) error {
+	_ = s.recordingSubscriber.Subscribe(subs)
+	close(s.done)
+	return nil
+}
+
+type blockingIngestor struct {
+	ticks []domain.Tick
+}
+
+func (b blockingIngestor) Run(ctx context.Context, out chan<- domain.Tick) error {
+	for _, tick := range b.ticks {
+		select {
+		case out <- tick:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func TestManagerRelaysTicksAndSubscribesInBackground(t *testing.T) {
+	subscriber := &signallingSubscriber{done: make(chan struct{})}
+	master := testMaster()
+	inner := blockingIngestor{ticks: []domain.Tick{spot(24480), {Token: "35004", ExchangeType: 2, LTP: 120}}}
+	m := New(inner, Options{
+		Chains:     []config.OptionChain{{Underlying: "NIFTY", ExchangeType: 1, Token: "99926000", Expiries: 1, Strikes: 0}},
+		Master:     func() *instruments.Master { return master },
+		Subscriber: subscriber,
+	})
+	m.now = func() time.Time { return time.Date(2024, 11, 20, 10, 0, 0, 0, time.UTC) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan domain.Tick, 2)
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx, out) }()
+
+	select {
+	case <-subscriber.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("window was never subscribed")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected both ticks relayed, got %d", len(out))
+	}
+	if fmt.Sprint(tokens(subscriber.subscribed[0])) != "[35004 35005]" {
+		t.Fatalf("unexpected ATM-only subscription: %+v", subscriber.subscribed)
+	}
+}
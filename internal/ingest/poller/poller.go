@@ -1,9 +1,8 @@
 package poller
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,116 +11,320 @@ import (
 	"example.com/e1/internal/auth"
 	"example.com/e1/internal/config"
 	"example.com/e1/internal/domain"
+	"example.com/e1/internal/instruments"
+	"example.com/e1/internal/smartapi"
 )
 
+const unfetchedLogInterval = time.Minute
+
+const maxThrottleBackoff = 5 * time.Minute
+
+var errAllDisabled = errors.New("all poller instruments are disabled")
+
 type Client struct {
-	cfg        config.Config
-	session    auth.Session
-	logger     *log.Logger
-	now        func() time.Time
-	httpClient *http.Client
+	cfg     config.Config
+	session auth.SessionProvider
+	logger  *log.Logger
+	now     func() time.Time
+	api     *smartapi.Client
+	limiter *rateLimiter
+	sleep   func(context.Context, time.Duration) error
+
+	throttledUntil  time.Time
+	throttleBackoff time.Duration
+
+	unfetched map[instrumentKey]*unfetchedState
+	disabled  map[instrumentKey]bool
 }
 
-type quoteResponse struct {
-	Status  bool   `json:"status"`
-	Message string `json:"message"`
-	Data    struct {
-		Fetched []struct {
-			Exchange      string  `json:"exchange"`
-			TradingSymbol string  `json:"tradingSymbol"`
-			SymbolToken   string  `json:"symbolToken"`
-			LTP           float64 `json:"ltp"`
-		} `json:"fetched"`
-	} `json:"data"`
+type instrumentKey struct {
+	exchange string
+	token    string
+}
+
+type unfetchedState struct {
+	misses     int
+	suppressed int
+	lastLogged time.Time
+}
+
+var exchangeLocation = time.FixedZone("IST", 5*60*60+30*60)
+
+type quoteData struct {
+	Fetched   []quoteItem     `json:"fetched"`
+	Unfetched []unfetchedItem `json:"unfetched"`
 }
 
-func New(cfg config.Config, session auth.Session, logger *log.Logger) *Client {
-	return &Client{
-		cfg:        cfg,
-		session:    session,
-		logger:     logger,
-		now:        time.Now,
-		httpClient: &http.Client{Timeout: 15 * time.Second},
+type unfetchedItem struct {
+	Exchange    string `json:"exchange"`
+	SymbolToken string `json:"symbolToken"`
+	Message     string `json:"message"`
+	ErrorCode   string `json:"errorCode"`
+}
+
+type quoteItem struct {
+	Exchange      string  `json:"exchange"`
+	TradingSymbol string  `json:"tradingSymbol"`
+	SymbolToken   string  `json:"symbolToken"`
+	LTP           float64 `json:"ltp"`
+	Open          float64 `json:"open"`
+	High          float64 `json:"high"`
+	Low           float64 `json:"low"`
+	Close         float64 `json:"close"`
+	LastTradeQty  float64 `json:"lastTradeQty"`
+	ExchFeedTime  string  `json:"exchFeedTime"`
+	ExchTradeTime string  `json:"exchTradeTime"`
+	AvgPrice      float64 `json:"avgPrice"`
+	TradeVolume   float64 `json:"tradeVolume"`
+	OpenInterest  float64 `json:"opnInterest"`
+	LowerCircuit  float64 `json:"lowerCircuit"`
+	UpperCircuit  float64 `json:"upperCircuit"`
+	TotBuyQuan    float64 `json:"totBuyQuan"`
+	TotSellQuan   float64 `json:"totSellQuan"`
+	Low52Week     float64 `json:"52WeekLow"`
+	High52Week    float64 `json:"52WeekHigh"`
+	Depth         *struct {
+		Buy  []quoteDepthLevel `json:"buy"`
+		Sell []quoteDepthLevel `json:"sell"`
+	} `json:"depth"`
+}
+
+type quoteDepthLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+	Orders   float64 `json:"orders"`
+}
+
+func New(cfg config.Config, session auth.SessionProvider, logger *log.Logger) *Client {
+	c := &Client{
+		cfg:       cfg,
+		session:   session,
+		logger:    logger,
+		now:       time.Now,
+		api:       smartapi.NewClient(cfg.APIKey),
+		sleep:     sleepContext,
+		unfetched: make(map[instrumentKey]*unfetchedState),
+		disabled:  make(map[instrumentKey]bool),
 	}
+	c.limiter = newRateLimiter(cfg.PollerRatePerSecond, cfg.PollerRatePerMinute, func() time.Time { return c.now() })
+	return c
 }
 
 func (c *Client) SetHTTPClient(client *http.Client) {
-	c.httpClient = client
+	c.api.SetHTTPClient(client)
 }
 
 func (c *Client) Run(ctx context.Context, out chan<- domain.Tick) error {
 	ticker := time.NewTicker(c.cfg.PollInterval)
 	defer ticker.Stop()
 
-	if err := c.pollOnce(ctx, out); err != nil && c.logger != nil {
-		c.logger.Printf("poller request failed: %v", err)
-	}
-
 	for {
+		if err := c.pollOnce(ctx, out); errors.Is(err, errAllDisabled) {
+			return err
+		} else if err != nil && ctx.Err() == nil {
+			c.logf("poller request failed: %v", err)
+		}
+
 		select {
 		case <-ctx.Done():
 			return nil
 		case <-ticker.C:
-			if err := c.pollOnce(ctx, out); err != nil && c.logger != nil {
-				c.logger.Printf("poller request failed: %v", err)
-			}
 		}
 	}
 }
 
 func (c *Client) pollOnce(ctx context.Context, out chan<- domain.Tick) error {
-	requestBody := map[string]any{
-		"mode":           c.cfg.PollerMode,
-		"exchangeTokens": buildExchangeTokens(c.cfg.PollerInstruments),
+	instruments := c.activeInstruments()
+	if len(instruments) == 0 {
+		return errAllDisabled
 	}
-	body, err := json.Marshal(requestBody)
-	if err != nil {
-		return fmt.Errorf("marshal quote request: %w", err)
+	if c.now().Before(c.throttledUntil) {
+		return nil
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.QuoteURL, bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("create quote request: %w", err)
-	}
-	req.Header.Set("Authorization", c.session.JWTToken)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("X-UserType", "USER")
-	req.Header.Set("X-SourceID", "WEB")
-	req.Header.Set("X-ClientLocalIP", "127.0.0.1")
-	req.Header.Set("X-ClientPublicIP", "127.0.0.1")
-	req.Header.Set("X-MACAddress", "00:00:00:00:00:00")
-	req.Header.Set("X-PrivateKey", c.session.APIKey)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("quote request: %w", err)
+	chunks := chunkInstruments(instruments, c.cfg.PollerMaxTokensPerRequest)
+	spacing := c.cfg.PollInterval / time.Duration(len(chunks))
+	start := c.now()
+	var errs []error
+	for i, chunk := range chunks {
+		if err := c.sleep(ctx, start.Add(time.Duration(i)*spacing).Sub(c.now())); err != nil {
+			return nil
+		}
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil
+		}
+		err := c.fetch(ctx, chunk, out)
+		if errors.Is(err, smartapi.ErrUnauthorized) {
+			c.logf("poller session rejected, refreshing: %v", err)
+			if _, refreshErr := c.session.Refresh(ctx); refreshErr != nil {
+				errs = append(errs, fmt.Errorf("%w (refresh: %v)", err, refreshErr))
+				continue
+			}
+			err = c.fetch(ctx, chunk, out)
+		}
+		if errors.Is(err, smartapi.ErrRateLimited) {
+			c.throttle(err)
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
-	defer resp.Body.Close()
+	c.throttleBackoff = 0
+	return errors.Join(errs...)
+}
 
-	var result quoteResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return fmt.Errorf("decode quote response: %w", err)
+func (c *Client) fetch(ctx context.Context, instruments []config.PollerInstrument, out chan<- domain.Tick) error {
+	requestBody := map[string]any{
+		"mode":           c.cfg.PollerMode,
+		"exchangeTokens": buildExchangeTokens(instruments),
 	}
-	if !result.Status {
-		return fmt.Errorf("quote request failed: %s", result.Message)
+	var data quoteData
+	if err := c.api.Post(ctx, c.cfg.QuoteURL, c.session.Current().JWTToken, requestBody, &data); err != nil {
+		return fmt.Errorf("quote request failed: %w", err)
 	}
 
 	now := c.now().UTC()
-	for _, item := range result.Data.Fetched {
-		out <- domain.Tick{
-			Source:        domain.SourcePoller,
-			Token:         item.SymbolToken,
-			Exchange:      item.Exchange,
-			TradingSymbol: item.TradingSymbol,
-			EventTime:     now,
-			ReceivedAt:    now,
-			LTP:           item.LTP,
+	for _, item := range data.Fetched {
+		delete(c.unfetched, instrumentKey{exchange: item.Exchange, token: item.SymbolToken})
+		select {
+		case out <- item.tick(now):
+		case <-ctx.Done():
+			return nil
 		}
 	}
+	for _, item := range data.Unfetched {
+		c.recordUnfetched(item, now)
+	}
 	return nil
 }
 
+func (c *Client) throttle(err error) {
+	c.throttleBackoff = min(max(c.throttleBackoff*2, c.cfg.PollInterval, time.Second), maxThrottleBackoff)
+	c.throttledUntil = c.now().Add(c.throttleBackoff)
+	c.logf("poller throttled, pausing requests for %s: %v", c.throttleBackoff, err)
+}
+
+func chunkInstruments(instruments []config.PollerInstrument, size int) [][]config.PollerInstrument {
+	if size <= 0 {
+		size = len(instruments)
+	}
+	var chunks [][]config.PollerInstrument
+	for len(instruments) > size {
+		chunks = append(chunks, instruments[:size])
+		instruments = instruments[size:]
+	}
+	return append(chunks, instruments)
+}
+
+func (c *Client) activeInstruments() []config.PollerInstrument {
+	active := make([]config.PollerInstrument, 0, len(c.cfg.PollerInstruments))
+	for _, instrument := range c.cfg.PollerInstruments {
+		if !c.disabled[instrumentKey{exchange: instrument.Exchange, token: instrument.SymbolToken}] {
+			active = append(active, instrument)
+		}
+	}
+	return active
+}
+
+func (c *Client) recordUnfetched(item unfetchedItem, now time.Time) {
+	key := instrumentKey{exchange: item.Exchange, token: item.SymbolToken}
+	state := c.unfetched[key]
+	if state == nil {
+		state = &unfetchedState{}
+		c.unfetched[key] = state
+	}
+	state.misses++
+
+	if c.cfg.PollerUnfetchedMax > 0 && state.misses >= c.cfg.PollerUnfetchedMax {
+		c.disabled[key] = true
+		delete(c.unfetched, key)
+		c.logf("poller: disabling %s token %s after %d unfetched polls (%s: %s); check POLLER_INSTRUMENTS", item.Exchange, item.SymbolToken, state.misses, item.ErrorCode, item.Message)
+		return
+	}
+	if !state.lastLogged.IsZero() && now.Sub(state.lastLogged) < unfetchedLogInterval {
+		state.suppressed++
+		return
+	}
+	if state.suppressed > 0 {
+		c.logf("poller: %s token %s unfetched (%s: %s), %d similar messages suppressed", item.Exchange, item.SymbolToken, item.ErrorCode, item.Message, state.suppressed)
+	} else {
+		c.logf("poller: %s token %s unfetched (%s: %s)", item.Exchange, item.SymbolToken, item.ErrorCode, item.Message)
+	}
+	state.lastLogged = now
+	state.suppressed = 0
+}
+
+func (c *Client) logf(format string, args ...any) {
+	if c.logger != nil {
+		c.logger.Printf(format, args...)
+	}
+}
+
+func (item quoteItem) tick(now time.Time) domain.Tick {
+	exchangeType, _ := instruments.ExchangeType(item.Exchange)
+	tick := domain.Tick{
+		Source:         domain.SourcePoller,
+		Token:          item.SymbolToken,
+		Exchange:       item.Exchange,
+		ExchangeType:   exchangeType,
+		TradingSymbol:  item.TradingSymbol,
+		EventTime:      now,
+		ReceivedAt:     now,
+		LTP:            item.LTP,
+		OpenPrice:      item.Open,
+		HighPrice:      item.High,
+		LowPrice:       item.Low,
+		ClosePrice:     item.Close,
+		LastTradedQty:  int64(item.LastTradeQty),
+		AvgTradedPrice: item.AvgPrice,
+		Volume:         int64(item.TradeVolume),
+		OpenInterest:   int64(item.OpenInterest),
+		LowerCircuit:   item.LowerCircuit,
+		UpperCircuit:   item.UpperCircuit,
+		TotalBuyQty:    item.TotBuyQuan,
+		TotalSellQty:   item.TotSellQuan,
+		Low52Week:      item.Low52Week,
+		High52Week:     item.High52Week,
+		LastTradedTime: parseExchangeTime(item.ExchTradeTime),
+	}
+	if feed := parseExchangeTime(item.ExchFeedTime); !feed.IsZero() {
+		tick.EventTime = feed
+	} else if !tick.LastTradedTime.IsZero() {
+		tick.EventTime = tick.LastTradedTime
+	}
+	if item.Depth != nil {
+		tick.Depth = &domain.Depth{
+			Bids: depthLevels(item.Depth.Buy),
+			Asks: depthLevels(item.Depth.Sell),
+		}
+	}
+	return tick
+}
+
+func depthLevels(levels []quoteDepthLevel) []domain.DepthLevel {
+	out := make([]domain.DepthLevel, 0, len(levels))
+	for _, level := range levels {
+		out = append(out, domain.DepthLevel{
+			Price:    level.Price,
+			Quantity: int64(level.Quantity),
+			Orders:   int(level.Orders),
+		})
+	}
+	return out
+}
+
+func parseExchangeTime(raw string) time.Time {
+	if raw == "" {
+		return time.Time{}
+	}
+	value, err := time.ParseInLocation("02-Jan-2006 15:04:05", raw, exchangeLocation)
+	if err != nil {
+		return time.Time{}
+	}
+	return value.UTC()
+}
+
 func buildExchangeTokens(instruments []config.PollerInstrument) map[string][]string {
 	exchangeTokens := make(map[string][]string)
 	for _, instrument := range instruments {
@@ -28,7 +28,7 @@ func TestPollerMapsResponseToTicks(t *testing.T) {
 		},
 		QuoteURL: "http://example.test/quote",
 	}
-	client := New(cfg, auth.Session{
+	client := New(cfg, auth.StaticSession{
 		APIKey:   "key",
 		JWTToken: "Bearer jwt",
 	}, log.New(io.Discard, "", 0))
@@ -66,7 +66,7 @@ func TestPollerHandlesEmptyFetchedData(t *testing.T) {
 		PollerInstruments: []config.PollerInstrument{{Exchange: "NSE", SymbolToken: "99926000"}},
 		QuoteURL:          "http://example.test/quote",
 	}
-	client := New(cfg, auth.Session{APIKey: "key", JWTToken: "Bearer jwt"}, log.New(io.Discard, "", 0))
+	client := New(cfg, auth.StaticSession{APIKey: "key", JWTToken: "Bearer jwt"}, log.New(io.Discard, "", 0))
 	client.SetHTTPClient(&http.Client{
 		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
 			return &http.Response{
@@ -86,3 +86,276 @@ func TestPollerHandlesEmptyFetchedData(t *testing.T) {
 	default:
 	}
 }
+
+func TestPollerDecodesFullQuote(t *testing.T) {
+	cfg := config.Config{
+		PollerMode: "FULL",
+		PollerInstruments: []config.PollerInstrument{
+			{Exchange: "NSE", SymbolToken: "3045"},
+		},
+		QuoteURL: "http://example.test/quote",
+	}
+	client := New(cfg, auth.StaticSession{APIKey: "key", JWTToken: "Bearer jwt"}, log.New(io.Discard, "", 0))
+	client.now = func() time.Time { return time.Unix(1710000000, 0).UTC() }
+	client.SetHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(strings.NewReader(`{
+					"status": true,
+					"data": {
+						"fetched": [{
+							"exchange": "NSE", "tradingSymbol": "SBIN-EQ", "symbolToken": "3045",
+							"ltp": 571.8, "open": 568.2, "high": 574.4, "low": 566.1, "close": 567.5,
+							"lastTradeQty": 12, "exchFeedTime": "21-Mar-2024 13:10:05",
+							"exchTradeTime": "21-Mar-2024 13:10:04", "avgPrice": 570.25,
+							"tradeVolume": 1250000, "opnInterest": 0,
+							"lowerCircuit": 510.75, "upperCircuit": 624.25,
+							"totBuyQuan": 40000, "totSellQuan": 52000,
+							"52WeekLow": 501.35, "52WeekHigh": 793.4,
+							"depth": {
+								"buy": [{"price": 571.75, "quantity": 150, "orders": 3}],
+								"sell": [{"price": 571.85, "quantity": 90, "orders": 2}]
+							}
+						}],
+						"unfetched": []
+					}
+				}`)),
+			}, nil
+		}),
+	})
+
+	out := make(chan domain.Tick, 1)
+	if err := client.pollOnce(context.Background(), out); err != nil {
+		t.Fatalf("pollOnce() error = %v", err)
+	}
+
+	tick := <-out
+	if tick.OpenPrice != 568.2 || tick.HighPrice != 574.4 || tick.LowPrice != 566.1 || tick.ClosePrice != 567.5 {
+		t.Fatalf("unexpected ohlc: %+v", tick)
+	}
+	if tick.Volume != 1250000 || tick.LastTradedQty != 12 || tick.AvgTradedPrice != 570.25 {
+		t.Fatalf("unexpected volume fields: %+v", tick)
+	}
+	if tick.TotalBuyQty != 40000 || tick.TotalSellQty != 52000 || tick.LowerCircuit != 510.75 || tick.UpperCircuit != 624.25 {
+		t.Fatalf("unexpected book fields: %+v", tick)
+	}
+	if tick.Low52Week != 501.35 || tick.High52Week != 793.4 {
+		t.Fatalf("unexpected 52 week range: %+v", tick)
+	}
+	wantTraded := time.Date(2024, 3, 21, 7, 40, 4, 0, time.UTC)
+	if !tick.LastTradedTime.Equal(wantTraded) {
+		t.Fatalf("LastTradedTime = %v, want %v", tick.LastTradedTime, wantTraded)
+	}
+	wantEvent := time.Date(2024, 3, 21, 7, 40, 5, 0, time.UTC)
+	if !tick.EventTime.Equal(wantEvent) || !tick.ReceivedAt.Equal(time.Unix(1710000000, 0)) {
+		t.Fatalf("EventTime = %v ReceivedAt = %v, want %v and poll time", tick.EventTime, tick.ReceivedAt, wantEvent)
+	}
+	if tick.Depth == nil || len(tick.Depth.Bids) != 1 || len(tick.Depth.Asks) != 1 {
+		t.Fatalf("unexpected depth: %+v", tick.Depth)
+	}
+	if bid := tick.Depth.Bids[0]; bid.Price != 571.75 || bid.Quantity != 150 || bid.Orders != 3 {
+		t.Fatalf("unexpected bid: %+v", bid)
+	}
+}
+
+func TestPollerReportsAndDisablesUnfetchedTokens(t *testing.T) {
+	cfg := config.Config{
+		PollerMode: "LTP",
+		PollerInstruments: []config.PollerInstrument{
+			{Exchange: "NSE", SymbolToken: "3045"},
+			{Exchange: "NSE", SymbolToken: "bogus"},
+		},
+		PollerUnfetchedMax: 3,
+		QuoteURL:           "http://example.test/quote",
+	}
+	var logs strings.Builder
+	client := New(cfg, auth.StaticSession{APIKey: "key", JWTToken: "Bearer jwt"}, log.New(&logs, "", 0))
+	now := time.Unix(1710000000, 0).UTC()
+	client.now = func() time.Time { return now }
+
+	var requested []string
+	client.SetHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			requested = append(requested, string(body))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(strings.NewReader(`{
+					"status": true,
+					"data": {
+						"fetched": [{"exchange":"NSE","tradingSymbol":"SBIN-EQ","symbolToken":"3045","ltp":571.8}],
+						"unfetched": [{"exchange":"NSE","symbolToken":"bogus","message":"Invalid Token","errorCode":"AB4018"}]
+					}
+				}`)),
+			}, nil
+		}),
+	})
+
+	out := make(chan domain.Tick, 10)
+	for i := 0; i < 3; i++ {
+		if err := client.pollOnce(context.Background(), out); err != nil {
+			t.Fatalf("pollOnce() error = %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	if got := strings.Count(logs.String(), "token bogus unfetched (AB4018: Invalid Token)"); got != 1 {
+		t.Fatalf("expected one rate-limited report, got %d in:\n%s", got, logs.String())
+	}
+	if !strings.Contains(logs.String(), "disabling NSE token bogus after 3 unfetched polls") {
+		t.Fatalf("expected disable message, got:\n%s", logs.String())
+	}
+
+	if err := client.pollOnce(context.Background(), out); err != nil {
+		t.Fatalf("pollOnce() error = %v", err)
+	}
+	if last := requested[len(requested)-1]; strings.Contains(last, "bogus") || !strings.Contains(last, "3045") {
+		t.Fatalf("disabled token still requested: %s", last)
+	}
+}
+
+func TestPollerChunksInstrumentsAcrossInterval(t *testing.T) {
+	cfg := config.Config{
+		PollerMode: "LTP",
+		PollerInstruments: []config.PollerInstrument{
+			{Exchange: "NSE", SymbolToken: "1"},
+			{Exchange: "NSE", SymbolToken: "2"},
+			{Exchange: "NSE", SymbolToken: "3"},
+		},
+		PollInterval:              time.Second,
+		PollerMaxTokensPerRequest: 2,
+		QuoteURL:                  "http://example.test/quote",
+	}
+	client := New(cfg, auth.StaticSession{APIKey: "key", JWTToken: "Bearer jwt"}, log.New(io.Discard, "", 0))
+	now := time.Unix(1710000000, 0).UTC()
+	client.now = func() time.Time { return now }
+	var slept []time.Duration
+	client.sleep = func(_ context.Context, d time.Duration) error {
+		if d > 0 {
+			slept = append(slept, d)
+			now = now.Add(d)
+		}
+		return nil
+	}
+
+	var requested []string
+	client.SetHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			requested = append(requested, string(body))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"status": true, "data": {"fetched": []}}`)),
+			}, nil
+		}),
+	})
+
+	if err := client.pollOnce(context.Background(), make(chan domain.Tick, 3)); err != nil {
+		t.Fatalf("pollOnce() error = %v", err)
+	}
+	if len(requested) != 2 {
+		t.Fatalf("expected 2 chunked requests, got %d: %v", len(requested), requested)
+	}
+	if !strings.Contains(requested[0], `["1","2"]`) || !strings.Contains(requested[1], `["3"]`) {
+		t.Fatalf("unexpected chunks: %v", requested)
+	}
+	if len(slept) != 1 || slept[0] != 500*time.Millisecond {
+		t.Fatalf("expected chunks spread over the interval, slept %v", slept)
+	}
+}
+
+func TestPollerBacksOffWhenThrottled(t *testing.T) {
+	cfg := config.Config{
+		PollerMode: "LTP",
+		PollerInstruments: []config.PollerInstrument{
+			{Exchange: "NSE", SymbolToken: "3045"},
+		},
+		PollInterval: time.Second,
+		QuoteURL:     "http://example.test/quote",
+	}
+	client := New(cfg, auth.StaticSession{APIKey: "key", JWTToken: "Bearer jwt"}, log.New(io.Discard, "", 0))
+	now := time.Unix(1710000000, 0).UTC()
+	client.now = func() time.Time { return now }
+
+	requests := 0
+	client.SetHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests++
+			return &http.Response{
+				StatusCode: http.StatusForbidden,
+				Body:       io.NopCloser(strings.NewReader(`Access denied because of exceeding access rate`)),
+			}, nil
+		}),
+	})
+
+	out := make(chan domain.Tick, 1)
+	for i := 0; i < 2; i++ {
+		if err := client.pollOnce(context.Background(), out); err != nil {
+			t.Fatalf("pollOnce() error = %v", err)
+		}
+		now = now.Add(500 * time.Millisecond)
+	}
+	if requests != 1 {
+		t.Fatalf("expected requests to pause after throttling, got %d", requests)
+	}
+
+	if err := client.pollOnce(context.Background(), out); err != nil {
+		t.Fatalf("pollOnce() error = %v", err)
+	}
+	if requests != 2 || client.throttleBackoff != 2*time.Second {
+		t.Fatalf("expected a retry with doubled backoff, requests=%d backoff=%v", requests, client.throttleBackoff)
+	}
+}
+
+type refreshingSession struct {
+	session   auth.Session
+	refreshes int
+}
+
+func (s *refreshingSession) Current() auth.Session {
+	return s.session
+}
+
+func (s *refreshingSession) Refresh(context.Context) (auth.Session, error) {
+	s.refreshes++
+	s.session.JWTToken = "Bearer fresh"
+	return s.session, nil
+}
+
+func TestPollerRefreshesSessionOnAuthError(t *testing.T) {
+	cfg := config.Config{
+		PollerMode: "LTP",
+		PollerInstruments: []config.PollerInstrument{
+			{Exchange: "NSE", SymbolToken: "3045"},
+		},
+		QuoteURL: "http://example.test/quote",
+	}
+	session := &refreshingSession{session: auth.Session{APIKey: "key", JWTToken: "Bearer stale"}}
+	client := New(cfg, session, log.New(io.Discard, "", 0))
+	client.SetHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") != "Bearer fresh" {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader(`{"status":false,"message":"Invalid Token","errorcode":"AG8001","data":null}`)),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"status":true,"data":{"fetched":[{"exchange":"NSE","symbolToken":"3045","ltp":571.8}]}}`)),
+			}, nil
+		}),
+	})
+
+	out := make(chan domain.Tick, 1)
+	if err := client.pollOnce(context.Background(), out); err != nil {
+		t.Fatalf("pollOnce() error = %v", err)
+	}
+	if session.refreshes != 1 {
+		t.Fatalf("expected one refresh, got %d", session.refreshes)
+	}
+	if tick := <-out; tick.LTP != 571.8 {
+		t.Fatalf("unexpected tick after retry: %+v", tick)
+	}
+}
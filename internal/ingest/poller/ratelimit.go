@@ -0,0 +1,95 @@
+package poller
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type tokenBucket struct {
+	capacity float64
+	rate     float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(limit int, per time.Duration, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(limit),
+		rate:     float64(limit) / per.Seconds(),
+		tokens:   float64(limit),
+		last:     now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+type rateLimiter struct {
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+
+	mu      sync.Mutex
+	buckets []*tokenBucket
+}
+
+func newRateLimiter(perSecond, perMinute int, now func() time.Time) *rateLimiter {
+	limiter := &rateLimiter{now: now, sleep: sleepContext}
+	start := now()
+	if perSecond > 0 {
+		limiter.buckets = append(limiter.buckets, newTokenBucket(perSecond, time.Second, start))
+	}
+	if perMinute > 0 {
+		limiter.buckets = append(limiter.buckets, newTokenBucket(perMinute, time.Minute, start))
+	}
+	return limiter
+}
+
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := l.now()
+		var delay time.Duration
+		for _, bucket := range l.buckets {
+			delay = max(delay, bucket.wait(now))
+		}
+		if delay == 0 {
+			for _, bucket := range l.buckets {
+				bucket.tokens--
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		if err := l.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,46 @@
+package poller
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterEnforcesPerSecondAndPerMinuteLimits(t *testing.T) {
+	now := time.Unix(1710000000, 0)
+	limiter := newRateLimiter(2, 3, func() time.Time { return now })
+	var slept []time.Duration
+	limiter.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		now = now.Add(d)
+		return nil
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+
+	var total time.Duration
+	for _, d := range slept {
+		total += d
+	}
+	if total < 19*time.Second {
+		t.Fatalf("expected the per-minute bucket to delay the fourth request by ~20s, slept %v", slept)
+	}
+}
+
+func TestRateLimiterStopsOnContextCancel(t *testing.T) {
+	now := time.Unix(1710000000, 0)
+	limiter := newRateLimiter(1, 0, func() time.Time { return now })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := limiter.Wait(ctx); err != nil {
+		t.Fatalf("first Wait() error = %v", err)
+	}
+	if err := limiter.Wait(ctx); err == nil {
+		t.Fatal("expected context error once the bucket is empty")
+	}
+}
@@ -0,0 +1,175 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"example.com/e1/internal/domain"
+	ws "example.com/e1/internal/ingest/websocket"
+)
+
+type TickReader interface {
+	ReadTicks(ctx context.Context, from, to time.Time, fn func(domain.Tick) error) error
+}
+
+type Options struct {
+	CaptureFiles []string
+	Ticks        TickReader
+	From         time.Time
+	To           time.Time
+	Speed        float64
+	Logger       *log.Logger
+}
+
+type Client struct {
+	opts  Options
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+
+	started   time.Time
+	firstSeen time.Time
+}
+
+func New(opts Options) *Client {
+	return &Client{
+		opts:  opts,
+		now:   time.Now,
+		sleep: sleepContext,
+	}
+}
+
+func (c *Client) Run(ctx context.Context, out chan<- domain.Tick) error {
+	emitted := 0
+	emit := func(tick domain.Tick) error {
+		if err := c.pace(ctx, tick.ReceivedAt); err != nil {
+			return err
+		}
+		tick.Source = domain.SourceReplay
+		select {
+		case out <- tick:
+			emitted++
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var err error
+	if len(c.opts.CaptureFiles) > 0 {
+		err = c.replayCaptures(ctx, emit)
+	} else if c.opts.Ticks != nil {
+		err = c.opts.Ticks.ReadTicks(ctx, c.opts.From, c.opts.To, emit)
+	} else {
+		return fmt.Errorf("replay needs capture files or a tick reader")
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	c.logf("replay finished after %d ticks", emitted)
+	return nil
+}
+
+func (c *Client) replayCaptures(ctx context.Context, emit func(domain.Tick) error) error {
+	for _, pattern := range c.opts.CaptureFiles {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("expand %s: %w", pattern, err)
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no capture files match %s", pattern)
+		}
+		for _, path := range paths {
+			if err := c.replayCapture(ctx, path, emit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) replayCapture(ctx context.Context, path string, emit func(domain.Tick) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open capture: %w", err)
+	}
+	defer file.Close()
+
+	reader := ws.NewCaptureReader(file)
+	for {
+		frame, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		if !c.inRange(frame.ReceivedAt) {
+			continue
+		}
+
+		tick, err := ws.ParseBinaryTick(frame.Payload, func() time.Time { return frame.ReceivedAt })
+		if err != nil {
+			c.logf("replay: skip frame from %s at %s: %v", path, frame.ReceivedAt.Format(time.RFC3339Nano), err)
+			continue
+		}
+		if tick == nil {
+			continue
+		}
+		if err := emit(*tick); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) inRange(at time.Time) bool {
+	if !c.opts.From.IsZero() && at.Before(c.opts.From) {
+		return false
+	}
+	if !c.opts.To.IsZero() && !at.Before(c.opts.To) {
+		return false
+	}
+	return true
+}
+
+func (c *Client) pace(ctx context.Context, at time.Time) error {
+	if c.opts.Speed <= 0 || at.IsZero() {
+		return nil
+	}
+	if c.firstSeen.IsZero() {
+		c.firstSeen = at
+		c.started = c.now()
+		return nil
+	}
+	offset := time.Duration(float64(at.Sub(c.firstSeen)) / c.opts.Speed)
+	wait := c.started.Add(offset).Sub(c.now())
+	if wait <= 0 {
+		return nil
+	}
+	return c.sleep(ctx, wait)
+}
+
+func (c *Client) logf(format string, args ...any) {
+	if c.opts.Logger != nil {
+		c.opts.Logger.Printf(format, args...)
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+package replay
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/e1/internal/domain"
+	ws "example.com/e1/internal/ingest/websocket"
+)
+
+func ltpFrame(token string, ltp uint64) []byte {
+	payload := make([]byte, 51)
+	payload[0] = 1
+	payload[1] = 1
+	copy(payload[2:], []byte(token))
+	binary.LittleEndian.PutUint64(payload[35:43], uint64(1710000000000))
+	binary.LittleEndian.PutUint64(payload[43:51], ltp)
+	return payload
+}
+
+type fakeTickReader struct {
+	ticks []domain.Tick
+}
+
+func (r fakeTickReader) ReadTicks(ctx context.Context, from, to time.Time, fn func(domain.Tick) error) error {
+	for _, tick := range r.ticks {
+		if err := fn(tick); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestReplayPlaysCaptureFilesAndSkipsBadFrames(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := ws.NewCaptureWriter(dir, time.UTC)
+	if err != nil {
+		t.Fatalf("NewCaptureWriter() error = %v", err)
+	}
+	start := time.Date(2026, 10, 16, 4, 0, 0, 0, time.UTC)
+	_ = writer.Write(start, ltpFrame("2885", 15025))
+	_ = writer.Write(start.Add(time.Second), []byte{2, 1, 0})
+	_ = writer.Write(start.Add(2*time.Second), ltpFrame("1594", 140000))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	client := New(Options{
+		CaptureFiles: []string{filepath.Join(dir, "*.frames")},
+		Logger:       log.New(io.Discard, "", 0),
+	})
+	out := make(chan domain.Tick, 4)
+	if err := client.Run(context.Background(), out); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	close(out)
+
+	var ticks []domain.Tick
+	for tick := range out {
+		ticks = append(ticks, tick)
+	}
+	if len(ticks) != 2 {
+		t.Fatalf("expected 2 ticks, got %+v", ticks)
+	}
+	if ticks[0].Source != domain.SourceReplay || ticks[0].Token != "2885" || !ticks[0].ReceivedAt.Equal(start) {
+		t.Fatalf("unexpected first tick: %+v", ticks[0])
+	}
+	if ticks[1].Token != "1594" || ticks[1].LTP != 1400 {
+		t.Fatalf("unexpected second tick: %+v", ticks[1])
+	}
+}
+
+func TestReplayPacesStoredTicksBySpeed(t *testing.T) {
+	start := time.Date(2026, 10, 16, 4, 0, 0, 0, time.UTC)
+	reader := fakeTickReader{ticks: []domain.Tick{
+		{Source: domain.SourceWebsocket, Token: "a", ReceivedAt: start},
+		{Source: domain.SourceWebsocket, Token: "b", ReceivedAt: start.Add(10 * time.Second)},
+		{Source: domain.SourcePoller, Token: "c", ReceivedAt: start.Add(30 * time.Second)},
+	}}
+
+	client := New(Options{Ticks: reader, Speed: 10})
+	clock := start
+	var waits []time.Duration
+	client.now = func() time.Time { return clock }
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		clock = clock.Add(d)
+		return nil
+	}
+
+	out := make(chan domain.Tick, 3)
+	if err := client.Run(context.Background(), out); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(waits) != 2 || waits[0] != time.Second || waits[1] != 2*time.Second {
+		t.Fatalf("unexpected waits: %v", waits)
+	}
+	for i := 0; i < 3; i++ {
+		if tick := <-out; tick.Source != domain.SourceReplay {
+			t.Fatalf("expected replay source, got %+v", tick)
+		}
+	}
+}
+
+func TestReplayAsFastAsPossibleDoesNotSleep(t *testing.T) {
+	start := time.Date(2026, 10, 16, 4, 0, 0, 0, time.UTC)
+	reader := fakeTickReader{ticks: []domain.Tick{
+		{Token: "a", ReceivedAt: start},
+		{Token: "b", ReceivedAt: start.Add(time.Hour)},
+	}}
+	client := New(Options{Ticks: reader, Speed: 0})
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		t.Fatalf("unexpected sleep of %s", d)
+		return nil
+	}
+	if err := client.Run(context.Background(), make(chan domain.Tick, 2)); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"example.com/e1/internal/domain"
+)
+
+type Reconnecter interface {
+	Reconnect()
+}
+
+type MarketHours struct {
+	Location *time.Location
+	Open     time.Duration
+	Close    time.Duration
+}
+
+func (h MarketHours) Contains(t time.Time) bool {
+	if h.Location != nil {
+		t = t.In(h.Location)
+	}
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	return offset >= h.Open && offset < h.Close
+}
+
+type TokenKey struct {
+	ExchangeType int
+	Token        string
+}
+
+func (k TokenKey) String() string {
+	return fmt.Sprintf("%d:%s", k.ExchangeType, k.Token)
+}
+
+type WatchdogOptions struct {
+	Name        string
+	Window      time.Duration
+	MarketHours MarketHours
+	Expected    func() []TokenKey
+	Logger      *log.Logger
+}
+
+type Watchdog struct {
+	inner Ingestor
+	opts  WatchdogOptions
+	now   func() time.Time
+
+	mu          sync.Mutex
+	lastTick    time.Time
+	lastByToken map[TokenKey]time.Time
+	staleTokens map[TokenKey]bool
+
+	stalls      atomic.Uint64
+	tokenStalls atomic.Uint64
+}
+
+func NewWatchdog(inner Ingestor, opts WatchdogOptions) *Watchdog {
+	return &Watchdog{
+		inner:       inner,
+		opts:        opts,
+		now:         time.Now,
+		lastByToken: make(map[TokenKey]time.Time),
+		staleTokens: make(map[TokenKey]bool),
+	}
+}
+
+func (w *Watchdog) Run(ctx context.Context, out chan<- domain.Tick) error {
+	w.mu.Lock()
+	w.lastTick = w.now()
+	w.mu.Unlock()
+
+	relay := make(chan domain.Tick)
+	done := make(chan error, 1)
+	go func() {
+		done <- w.inner.Run(ctx, relay)
+		close(relay)
+	}()
+
+	interval := max(w.opts.Window/4, 10*time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case tick, ok := <-relay:
+			if !ok {
+				return <-done
+			}
+			w.observe(tick)
+			select {
+			case out <- tick:
+			case <-ctx.Done():
+			}
+		case <-ticker.C:
+			w.check(w.now())
+		}
+	}
+}
+
+func (w *Watchdog) Stalls() uint64 {
+	return w.stalls.Load()
+}
+
+func (w *Watchdog) TokenStalls() uint64 {
+	return w.tokenStalls.Load()
+}
+
+func (w *Watchdog) observe(tick domain.Tick) {
+	now := w.now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key := TokenKey{ExchangeType: tick.ExchangeType, Token: tick.Token}
+	w.lastTick = now
+	w.lastByToken[key] = now
+	if w.staleTokens[key] {
+		delete(w.staleTokens, key)
+		w.logf("%s: token %s is delivering data again", w.opts.Name, key)
+	}
+}
+
+func (w *Watchdog) check(now time.Time) {
+	var expected []TokenKey
+	if w.opts.Expected != nil {
+		expected = w.opts.Expected()
+	}
+
+	w.mu.Lock()
+	if !w.opts.MarketHours.Contains(now) {
+		w.lastTick = now
+		for token := range w.lastByToken {
+			w.lastByToken[token] = now
+		}
+		w.mu.Unlock()
+		return
+	}
+
+	if w.opts.Expected != nil {
+		w.trackExpected(expected, now)
+	}
+
+	quiet := now.Sub(w.lastTick)
+	stalled := quiet >= w.opts.Window
+	if stalled {
+		w.lastTick = now
+	} else {
+		for key, last := range w.lastByToken {
+			if w.staleTokens[key] || now.Sub(last) < w.opts.Window {
+				continue
+			}
+			w.staleTokens[key] = true
+			w.tokenStalls.Add(1)
+			w.logf("%s: no data for token %s in %s", w.opts.Name, key, now.Sub(last).Round(time.Second))
+		}
+	}
+	w.mu.Unlock()
+
+	if !stalled {
+		return
+	}
+	total := w.stalls.Add(1)
+	if reconnecter, ok := w.inner.(Reconnecter); ok {
+		w.logf("%s: no data in %s, forcing reconnect (stall %d)", w.opts.Name, quiet.Round(time.Second), total)
+		reconnecter.Reconnect()
+		return
+	}
+	w.logf("ALERT %s: no data in %s during market hours (stall %d)", w.opts.Name, quiet.Round(time.Second), total)
+}
+
+func (w *Watchdog) trackExpected(expected []TokenKey, now time.Time) {
+	wanted := make(map[TokenKey]bool, len(expected))
+	for _, key := range expected {
+		wanted[key] = true
+		if _, ok := w.lastByToken[key]; !ok {
+			w.lastByToken[key] = now
+		}
+	}
+	for key := range w.lastByToken {
+		if !wanted[key] {
+			delete(w.lastByToken, key)
+			delete(w.staleTokens, key)
+		}
+	}
+}
+
+func (w *Watchdog) logf(format string, args ...any) {
+	if w.opts.Logger != nil {
+		w.opts.Logger.Printf(format, args...)
+	}
+}
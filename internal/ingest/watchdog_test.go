@@ -0,0 +1,168 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"example.com/e1/internal/domain"
+)
+
+type fakeIngestor struct {
+	reconnects int
+}
+
+func (f *fakeIngestor) Run(ctx context.Context, out chan<- domain.Tick) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeIngestor) Reconnect() {
+	f.reconnects++
+}
+
+var ist = time.FixedZone("IST", 5*60*60+30*60)
+
+func testHours() MarketHours {
+	return MarketHours{Location: ist, Open: 9*time.Hour + 15*time.Minute, Close: 15*time.Hour + 30*time.Minute}
+}
+
+func TestMarketHoursContains(t *testing.T) {
+	hours := testHours()
+	cases := []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2026, 10, 16, 10, 0, 0, 0, ist), true},
+		{time.Date(2026, 10, 16, 9, 0, 0, 0, ist), false},
+		{time.Date(2026, 10, 16, 15, 30, 0, 0, ist), false},
+		{time.Date(2026, 10, 17, 10, 0, 0, 0, ist), false},
+		{time.Date(2026, 10, 16, 4, 30, 0, 0, time.UTC), true},
+	}
+	for _, tc := range cases {
+		if got := hours.Contains(tc.at); got != tc.want {
+			t.Fatalf("Contains(%s) = %t, want %t", tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestWatchdogForcesReconnectOnStall(t *testing.T) {
+	inner := &fakeIngestor{}
+	watchdog := NewWatchdog(inner, WatchdogOptions{
+		Name:        "websocket",
+		Window:      time.Minute,
+		MarketHours: testHours(),
+		Logger:      log.New(io.Discard, "", 0),
+	})
+
+	start := time.Date(2026, 10, 16, 10, 0, 0, 0, ist)
+	watchdog.now = func() time.Time { return start }
+	watchdog.lastTick = start
+	watchdog.observe(domain.Tick{Token: "2885"})
+
+	watchdog.check(start.Add(30 * time.Second))
+	if inner.reconnects != 0 || watchdog.Stalls() != 0 {
+		t.Fatalf("unexpected stall inside window")
+	}
+
+	watchdog.check(start.Add(61 * time.Second))
+	if inner.reconnects != 1 || watchdog.Stalls() != 1 {
+		t.Fatalf("expected one forced reconnect, got reconnects=%d stalls=%d", inner.reconnects, watchdog.Stalls())
+	}
+
+	watchdog.check(start.Add(90 * time.Second))
+	if inner.reconnects != 1 {
+		t.Fatalf("expected window to restart after reconnect, got %d reconnects", inner.reconnects)
+	}
+}
+
+func TestWatchdogIgnoresQuietMarketAndTracksTokens(t *testing.T) {
+	poller := ingestorFunc(func(ctx context.Context, out chan<- domain.Tick) error {
+		<-ctx.Done()
+		return nil
+	})
+	watchdog := NewWatchdog(poller, WatchdogOptions{
+		Name:        "poller",
+		Window:      time.Minute,
+		MarketHours: testHours(),
+		Logger:      log.New(io.Discard, "", 0),
+	})
+
+	closed := time.Date(2026, 10, 16, 8, 0, 0, 0, ist)
+	watchdog.lastTick = closed
+	watchdog.check(closed.Add(time.Hour))
+	if watchdog.Stalls() != 0 {
+		t.Fatal("did not expect a stall outside market hours")
+	}
+
+	open := time.Date(2026, 10, 16, 10, 0, 0, 0, ist)
+	watchdog.now = func() time.Time { return open }
+	watchdog.observe(domain.Tick{Token: "2885"})
+	watchdog.now = func() time.Time { return open.Add(50 * time.Second) }
+	watchdog.observe(domain.Tick{Token: "1594"})
+
+	watchdog.check(open.Add(70 * time.Second))
+	if watchdog.Stalls() != 0 || watchdog.TokenStalls() != 1 {
+		t.Fatalf("expected only token 2885 to be stale, stalls=%d token_stalls=%d", watchdog.Stalls(), watchdog.TokenStalls())
+	}
+
+	watchdog.check(open.Add(200 * time.Second))
+	if watchdog.Stalls() != 1 {
+		t.Fatalf("expected poller stall alert, got %d", watchdog.Stalls())
+	}
+}
+
+func TestWatchdogSeedsSubscribedTokensByExchange(t *testing.T) {
+	expected := []TokenKey{{ExchangeType: 1, Token: "2885"}, {ExchangeType: 2, Token: "2885"}}
+	watchdog := NewWatchdog(&fakeIngestor{}, WatchdogOptions{
+		Name:        "websocket",
+		Window:      time.Minute,
+		MarketHours: testHours(),
+		Expected:    func() []TokenKey { return expected },
+		Logger:      log.New(io.Discard, "", 0),
+	})
+
+	watchdog.check(time.Date(2026, 10, 16, 9, 0, 0, 0, ist))
+	open := time.Date(2026, 10, 16, 9, 15, 0, 0, ist)
+	watchdog.check(open)
+
+	for i := 1; i <= 7; i++ {
+		at := open.Add(time.Duration(i) * 10 * time.Second)
+		watchdog.now = func() time.Time { return at }
+		watchdog.observe(domain.Tick{ExchangeType: 1, Token: "2885"})
+		watchdog.check(at)
+	}
+	if watchdog.TokenStalls() != 1 || !watchdog.staleTokens[TokenKey{ExchangeType: 2, Token: "2885"}] {
+		t.Fatalf("expected only the silent NFO token to be stale, got %v", watchdog.staleTokens)
+	}
+
+	expected = expected[:1]
+	watchdog.check(open.Add(80 * time.Second))
+	if len(watchdog.lastByToken) != 1 || len(watchdog.staleTokens) != 0 {
+		t.Fatalf("expected unsubscribed token to be dropped, got %v", watchdog.lastByToken)
+	}
+}
+
+func TestWatchdogForwardsTicks(t *testing.T) {
+	inner := ingestorFunc(func(ctx context.Context, out chan<- domain.Tick) error {
+		out <- domain.Tick{Token: "2885"}
+		return nil
+	})
+	watchdog := NewWatchdog(inner, WatchdogOptions{Window: time.Minute, MarketHours: testHours()})
+
+	out := make(chan domain.Tick, 1)
+	if err := watchdog.Run(context.Background(), out); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if tick := <-out; tick.Token != "2885" {
+		t.Fatalf("unexpected tick: %+v", tick)
+	}
+}
+
+type ingestorFunc func(ctx context.Context, out chan<- domain.Tick) error
+
+func (f ingestorFunc) Run(ctx context.Context, out chan<- domain.Tick) error {
+	return f(ctx, out)
+}
@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	captureHeaderSize    = 12
+	maxCaptureFrame      = 4096
+	captureFlushInterval = time.Second
+)
+
+type CapturedFrame struct {
+	ReceivedAt time.Time
+	Payload    []byte
+}
+
+type CaptureWriter struct {
+	dir      string
+	location *time.Location
+
+	mu         sync.Mutex
+	day        string
+	file       *os.File
+	buf        *bufio.Writer
+	flushTimer *time.Timer
+	flushErr   error
+}
+
+func NewCaptureWriter(dir string, location *time.Location) (*CaptureWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create capture directory: %w", err)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &CaptureWriter{dir: dir, location: location}, nil
+}
+
+func CaptureFileName(day time.Time) string {
+	return fmt.Sprintf("smartstream-%s.frames", day.Format("2006-01-02"))
+}
+
+func (w *CaptureWriter) Write(receivedAt time.Time, payload []byte) error {
+	if len(payload) > maxCaptureFrame {
+		return fmt.Errorf("frame of %d bytes exceeds the %d byte capture limit, not captured", len(payload), maxCaptureFrame)
+	}
+	record := make([]byte, captureHeaderSize+len(payload))
+	binary.LittleEndian.PutUint64(record[0:8], uint64(receivedAt.UnixNano()))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(payload)))
+	copy(record[captureHeaderSize:], payload)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.flushErr; err != nil {
+		w.flushErr = nil
+		return fmt.Errorf("flush capture file: %w", err)
+	}
+	if err := w.rotate(receivedAt); err != nil {
+		return err
+	}
+	if _, err := w.buf.Write(record); err != nil {
+		return fmt.Errorf("write capture frame: %w", err)
+	}
+	if w.flushTimer == nil {
+		w.flushTimer = time.AfterFunc(captureFlushInterval, w.flushPending)
+	}
+	return nil
+}
+
+func (w *CaptureWriter) flushPending() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushTimer = nil
+	if w.buf != nil {
+		w.flushErr = w.buf.Flush()
+	}
+}
+
+func (w *CaptureWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.flushTimer != nil {
+		w.flushTimer.Stop()
+		w.flushTimer = nil
+	}
+	if w.file == nil {
+		return nil
+	}
+	err := w.closeFile()
+	w.day = ""
+	return err
+}
+
+func (w *CaptureWriter) closeFile() error {
+	flushErr := w.buf.Flush()
+	closeErr := w.file.Close()
+	w.file = nil
+	w.buf = nil
+	if flushErr != nil {
+		return fmt.Errorf("flush capture file: %w", flushErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("close capture file: %w", closeErr)
+	}
+	return nil
+}
+
+func (w *CaptureWriter) rotate(at time.Time) error {
+	local := at.In(w.location)
+	day := local.Format("2006-01-02")
+	if w.file != nil && w.day == day {
+		return nil
+	}
+	if w.file != nil {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(w.dir, CaptureFileName(local))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open capture file: %w", err)
+	}
+	w.file = file
+	w.buf = bufio.NewWriter(file)
+	w.day = day
+	return nil
+}
+
+type CaptureReader struct {
+	r *bufio.Reader
+}
+
+func NewCaptureReader(r io.Reader) *CaptureReader {
+	return &CaptureReader{r: bufio.NewReader(r)}
+}
+
+func (r *CaptureReader) Next() (CapturedFrame, error) {
+	var header [captureHeaderSize]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return CapturedFrame{}, fmt.Errorf("truncated capture header: %w", err)
+		}
+		return CapturedFrame{}, err
+	}
+	size := binary.LittleEndian.Uint32(header[8:12])
+	if size > maxCaptureFrame {
+		return CapturedFrame{}, fmt.Errorf("capture frame of %d bytes exceeds the %d byte limit, file is corrupt", size, maxCaptureFrame)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r.r, payload); err != nil {
+		return CapturedFrame{}, fmt.Errorf("truncated capture frame: %w", err)
+	}
+	return CapturedFrame{
+		ReceivedAt: time.Unix(0, int64(binary.LittleEndian.Uint64(header[0:8]))).UTC(),
+		Payload:    payload,
+	}, nil
+}
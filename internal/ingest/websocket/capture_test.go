@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureWriterRotatesDailyAndRoundTrips(t *testing.T) {
+	dir := t.TempDir()
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	writer, err := NewCaptureWriter(dir, ist)
+	if err != nil {
+		t.Fatalf("NewCaptureWriter() error = %v", err)
+	}
+
+	first := time.Date(2026, 10, 16, 15, 29, 0, 0, ist)
+	second := time.Date(2026, 10, 19, 9, 15, 0, 0, ist)
+	broken := []byte{2, 1, 0}
+	if err := writer.Write(first, ltpFrame("2885", 15025)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := writer.Write(first.Add(time.Second), broken); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := writer.Write(second, ltpFrame("2885", 15100)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, CaptureFileName(first)))
+	if err != nil {
+		t.Fatalf("open first capture: %v", err)
+	}
+	defer file.Close()
+
+	reader := NewCaptureReader(file)
+	frame, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if !frame.ReceivedAt.Equal(first) || len(frame.Payload) != 51 {
+		t.Fatalf("unexpected first frame: %+v", frame)
+	}
+	frame, err = reader.Next()
+	if err != nil || !bytes.Equal(frame.Payload, broken) {
+		t.Fatalf("expected unparseable frame to be kept, got %+v, err = %v", frame, err)
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, CaptureFileName(second))); err != nil {
+		t.Fatalf("expected a second capture file: %v", err)
+	}
+}
+
+func TestCaptureReaderReportsTruncatedFrame(t *testing.T) {
+	var buf bytes.Buffer
+	header := make([]byte, captureHeaderSize)
+	header[8] = 10
+	buf.Write(header)
+	buf.Write([]byte{1, 2, 3})
+
+	if _, err := NewCaptureReader(&buf).Next(); err == nil || errors.Is(err, io.EOF) {
+		t.Fatalf("expected truncation error, got %v", err)
+	}
+}
+
+func TestCaptureReaderRejectsOversizedFrame(t *testing.T) {
+	header := make([]byte, captureHeaderSize)
+	header[8], header[9], header[10], header[11] = 0xff, 0xff, 0xff, 0xff
+
+	_, err := NewCaptureReader(bytes.NewReader(header)).Next()
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("expected oversized frame error, got %v", err)
+	}
+}
+
+func TestCaptureWriterSkipsOversizedFramesAndFlushesWithoutClose(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := NewCaptureWriter(dir, time.UTC)
+	if err != nil {
+		t.Fatalf("NewCaptureWriter() error = %v", err)
+	}
+	defer writer.Close()
+
+	at := time.Date(2026, 10, 16, 4, 0, 0, 0, time.UTC)
+	if err := writer.Write(at, make([]byte, maxCaptureFrame+1)); err == nil {
+		t.Fatal("expected oversized frame to be rejected")
+	}
+	if err := writer.Write(at, ltpFrame("2885", 15025)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	path := filepath.Join(dir, CaptureFileName(at))
+	deadline := time.Now().Add(3 * captureFlushInterval)
+	for {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("open capture: %v", err)
+		}
+		frame, err := NewCaptureReader(file).Next()
+		file.Close()
+		if err == nil {
+			if len(frame.Payload) != 51 {
+				t.Fatalf("unexpected frame: %+v", frame)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("buffered frame was not flushed: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
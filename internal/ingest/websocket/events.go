@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var ErrAuthFailed = errors.New("websocket authentication failed")
+
+type textEventKind int
+
+const (
+	textEventUnknown textEventKind = iota
+	textEventPong
+	textEventError
+)
+
+type textEvent struct {
+	kind textEventKind
+	err  *ServerError
+	raw  string
+}
+
+type ServerError struct {
+	CorrelationID string
+	Code          string
+	Message       string
+	StatusCode    int
+}
+
+func (e *ServerError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("websocket handshake rejected with status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("websocket server error %s: %s", e.Code, e.Message)
+}
+
+func (e *ServerError) Unwrap() error {
+	if e.Fatal() {
+		return ErrAuthFailed
+	}
+	return nil
+}
+
+// Smart Stream documents these codes for request errors; none of them end the session.
+var serverErrorCodes = map[string]bool{
+	"E1001": false,
+	"E1002": false,
+}
+
+var fatalServerMessages = []string{
+	"invalid feed token",
+	"invalid client code",
+	"invalid api key",
+	"invalid auth token",
+	"invalid jwt token",
+}
+
+func (e *ServerError) Fatal() bool {
+	if e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden {
+		return true
+	}
+	if fatal, ok := serverErrorCodes[e.Code]; ok {
+		return fatal
+	}
+	message := strings.TrimSuffix(strings.TrimSpace(e.Message), ".")
+	for _, known := range fatalServerMessages {
+		if strings.EqualFold(message, known) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTextFrame(payload []byte) textEvent {
+	raw := strings.TrimSpace(string(payload))
+	if strings.EqualFold(raw, "pong") {
+		return textEvent{kind: textEventPong, raw: raw}
+	}
+
+	var body struct {
+		CorrelationID string `json:"correlationID"`
+		ErrorCode     string `json:"errorCode"`
+		ErrorMessage  string `json:"errorMessage"`
+	}
+	if err := json.Unmarshal(payload, &body); err == nil && (body.ErrorCode != "" || body.ErrorMessage != "") {
+		return textEvent{
+			kind: textEventError,
+			err: &ServerError{
+				CorrelationID: body.CorrelationID,
+				Code:          body.ErrorCode,
+				Message:       body.ErrorMessage,
+			},
+			raw: raw,
+		}
+	}
+	return textEvent{kind: textEventUnknown, raw: raw}
+}
+
+func handshakeError(resp *http.Response, err error) error {
+	if resp == nil || (resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden) {
+		return fmt.Errorf("dial websocket: %w", err)
+	}
+	message := resp.Header.Get("x-error-message")
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return &ServerError{StatusCode: resp.StatusCode, Message: message}
+}
@@ -0,0 +1,38 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseTextFrame(t *testing.T) {
+	if event := parseTextFrame([]byte("pong")); event.kind != textEventPong {
+		t.Fatalf("expected pong, got %+v", event)
+	}
+
+	event := parseTextFrame([]byte(`{"correlationID":"live-ingestor","errorCode":"E1002","errorMessage":"Invalid Request. Subscription Limit Exceeded"}`))
+	if event.kind != textEventError || event.err.Code != "E1002" || event.err.CorrelationID != "live-ingestor" {
+		t.Fatalf("unexpected error event: %+v", event)
+	}
+	if event.err.Fatal() || errors.Is(event.err, ErrAuthFailed) {
+		t.Fatalf("subscription limit should not be fatal: %v", event.err)
+	}
+
+	event = parseTextFrame([]byte(`{"errorCode":"E1005","errorMessage":"Invalid Feed Token"}`))
+	if event.kind != textEventError || !errors.Is(event.err, ErrAuthFailed) {
+		t.Fatalf("expected fatal auth error, got %+v", event)
+	}
+
+	for _, payload := range []string{
+		`{"errorCode":"E1001","errorMessage":"Invalid Feed Token"}`,
+		`{"errorCode":"E1009","errorMessage":"Author field missing"}`,
+	} {
+		if event := parseTextFrame([]byte(payload)); event.err.Fatal() {
+			t.Fatalf("expected %s to be non-fatal", payload)
+		}
+	}
+
+	if event := parseTextFrame([]byte("hello")); event.kind != textEventUnknown || event.raw != "hello" {
+		t.Fatalf("expected unknown event, got %+v", event)
+	}
+}
@@ -14,6 +14,7 @@ func TestParseBinaryTickLTP(t *testing.T) {
 	payload[0] = 1
 	payload[1] = 1
 	copy(payload[2:], []byte("99926000"))
+	binary.LittleEndian.PutUint64(payload[27:35], uint64(7))
 	binary.LittleEndian.PutUint64(payload[35:43], uint64(1710000000000))
 	binary.LittleEndian.PutUint64(payload[43:51], uint64(245678))
 
@@ -28,6 +29,9 @@ func TestParseBinaryTickLTP(t *testing.T) {
 	if tick.Token != "99926000" {
 		t.Fatalf("unexpected token: %q", tick.Token)
 	}
+	if tick.SequenceNumber != 7 {
+		t.Fatalf("unexpected sequence number: %d", tick.SequenceNumber)
+	}
 	if tick.LTP != 2456.78 {
 		t.Fatalf("unexpected ltp: %f", tick.LTP)
 	}
@@ -72,6 +76,9 @@ func TestParseBinaryTickSnapQuote(t *testing.T) {
 	copy(payload[2:], []byte("2885"))
 	binary.LittleEndian.PutUint64(payload[35:43], uint64(1710000000000))
 	binary.LittleEndian.PutUint64(payload[43:51], uint64(15025))
+	binary.LittleEndian.PutUint64(payload[123:131], uint64(1709999990))
+	binary.LittleEndian.PutUint64(payload[131:139], uint64(125000))
+	binary.LittleEndian.PutUint64(payload[139:147], math.Float64bits(2.5))
 	binary.LittleEndian.PutUint64(payload[347:355], uint64(15500))
 	binary.LittleEndian.PutUint64(payload[355:363], uint64(14000))
 	binary.LittleEndian.PutUint64(payload[363:371], uint64(16000))
@@ -84,12 +91,89 @@ func TestParseBinaryTickSnapQuote(t *testing.T) {
 	if tick.UpperCircuit != 155 || tick.Low52Week != 130 {
 		t.Fatalf("unexpected snap fields: %+v", tick)
 	}
+	if !tick.LastTradedTime.Equal(time.Unix(1709999990, 0)) {
+		t.Fatalf("unexpected last traded time: %s", tick.LastTradedTime)
+	}
+	if tick.OpenInterest != 125000 || tick.OIChangePct != 2.5 {
+		t.Fatalf("unexpected open interest fields: %+v", tick)
+	}
+}
+
+func TestParseBinaryTickSnapQuoteDepth(t *testing.T) {
+	payload := make([]byte, 379)
+	payload[0] = 3
+	payload[1] = 1
+	copy(payload[2:], []byte("2885"))
+	for i := 0; i < 10; i++ {
+		packet := payload[147+i*20 : 147+(i+1)*20]
+		if i < 5 {
+			binary.LittleEndian.PutUint16(packet[0:2], 1)
+		}
+		binary.LittleEndian.PutUint64(packet[2:10], uint64(100*(i+1)))
+		binary.LittleEndian.PutUint64(packet[10:18], uint64(15000+i*5))
+		binary.LittleEndian.PutUint16(packet[18:20], uint16(i+1))
+	}
+
+	tick, err := ParseBinaryTick(payload, time.Now)
+	if err != nil {
+		t.Fatalf("ParseBinaryTick() error = %v", err)
+	}
+	if tick.Depth == nil || len(tick.Depth.Bids) != 5 || len(tick.Depth.Asks) != 5 {
+		t.Fatalf("unexpected depth: %+v", tick.Depth)
+	}
+	if bid := tick.Depth.Bids[0]; bid.Price != 150 || bid.Quantity != 100 || bid.Orders != 1 {
+		t.Fatalf("unexpected best bid: %+v", bid)
+	}
+	if ask := tick.Depth.Asks[4]; ask.Price != 150.45 || ask.Quantity != 1000 || ask.Orders != 10 {
+		t.Fatalf("unexpected fifth ask: %+v", ask)
+	}
+}
+
+func TestParseBinaryTickDepth20(t *testing.T) {
+	payload := make([]byte, 443)
+	payload[0] = 4
+	payload[1] = 2
+	copy(payload[2:], []byte("35001"))
+	binary.LittleEndian.PutUint64(payload[35:43], uint64(1710000000000))
+	for i := 0; i < 20; i++ {
+		bid := payload[43+i*10 : 43+(i+1)*10]
+		binary.LittleEndian.PutUint32(bid[0:4], uint32(50*(i+1)))
+		binary.LittleEndian.PutUint32(bid[4:8], uint32(2200000-i*5))
+		binary.LittleEndian.PutUint16(bid[8:10], uint16(i+1))
+		ask := payload[243+i*10 : 243+(i+1)*10]
+		binary.LittleEndian.PutUint32(ask[0:4], uint32(75*(i+1)))
+		binary.LittleEndian.PutUint32(ask[4:8], uint32(2200005+i*5))
+		binary.LittleEndian.PutUint16(ask[8:10], uint16(i+2))
+	}
+
+	tick, err := ParseBinaryTick(payload, time.Now)
+	if err != nil {
+		t.Fatalf("ParseBinaryTick() error = %v", err)
+	}
+	if !tick.DepthOnly || tick.Token != "35001" || tick.ExchangeType != 2 {
+		t.Fatalf("unexpected depth tick: %+v", tick)
+	}
+	if !tick.EventTime.Equal(time.UnixMilli(1710000000000)) {
+		t.Fatalf("unexpected event time: %s", tick.EventTime)
+	}
+	if len(tick.Depth.Bids) != 20 || len(tick.Depth.Asks) != 20 {
+		t.Fatalf("expected 20 levels per side, got %d/%d", len(tick.Depth.Bids), len(tick.Depth.Asks))
+	}
+	if bid := tick.Depth.Bids[0]; bid.Price != 22000 || bid.Quantity != 50 || bid.Orders != 1 {
+		t.Fatalf("unexpected best bid: %+v", bid)
+	}
+	if ask := tick.Depth.Asks[19]; ask.Price != 22001 || ask.Quantity != 1500 || ask.Orders != 21 {
+		t.Fatalf("unexpected twentieth ask: %+v", ask)
+	}
 }
 
 func TestParseBinaryTickRejectsInvalidPayload(t *testing.T) {
 	if _, err := ParseBinaryTick([]byte{2}, time.Now); err == nil {
 		t.Fatal("expected invalid packet size error")
 	}
+	if _, err := ParseBinaryTick(append([]byte{4}, make([]byte, 100)...), time.Now); err == nil {
+		t.Fatal("expected invalid depth 20 packet size error")
+	}
 	if _, err := ParseBinaryTick([]byte{9, 1, 2}, time.Now); err == nil {
 		t.Fatal("expected unknown mode error")
 	}
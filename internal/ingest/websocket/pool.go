@@ -0,0 +1,322 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/e1/internal/auth"
+	"example.com/e1/internal/config"
+	"example.com/e1/internal/domain"
+	"example.com/e1/internal/ingest"
+)
+
+type Pool struct {
+	cfg       config.Config
+	session   auth.SessionProvider
+	logger    *log.Logger
+	maxTokens int
+	maxConns  int
+
+	mu        sync.Mutex
+	clients   []*Client
+	capture   *CaptureWriter
+	watchdog  *ingest.WatchdogOptions
+	watchdogs []*ingest.Watchdog
+
+	runCtx  context.Context
+	out     chan<- domain.Tick
+	results chan error
+	active  int
+}
+
+func NewPool(cfg config.Config, session auth.SessionProvider, logger *log.Logger) *Pool {
+	subs := newSubscriptionSet(cfg.WebsocketMode, cfg.WebsocketTokens).list()
+	shards := shardSubscriptions(subs, cfg.WebsocketMaxTokensPerConn)
+
+	pool := &Pool{
+		cfg:       cfg,
+		session:   session,
+		logger:    logger,
+		maxTokens: cfg.WebsocketMaxTokensPerConn,
+		maxConns:  max(cfg.WebsocketMaxConnections, len(shards)),
+	}
+	for _, shard := range shards {
+		pool.addClient(shard, len(shards) > 1)
+	}
+	if logger != nil && len(shards) > 1 {
+		logger.Printf("websocket subscriptions split across %d connections", len(shards))
+	}
+	return pool
+}
+
+func (p *Pool) addClient(subs []config.WebsocketSubscription, named bool) *Client {
+	shardCfg := p.cfg
+	shardCfg.WebsocketTokens = subs
+	client := New(shardCfg, p.session, p.logger)
+	if named || len(p.clients) > 0 {
+		client.name = fmt.Sprintf("[shard %d]", len(p.clients)+1)
+	}
+	if p.capture != nil {
+		client.SetCapture(p.capture)
+	}
+	p.clients = append(p.clients, client)
+	return client
+}
+
+func (p *Pool) SetCapture(capture *CaptureWriter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.capture = capture
+	for _, client := range p.clients {
+		client.SetCapture(capture)
+	}
+}
+
+func (p *Pool) SetWatchdog(opts ingest.WatchdogOptions) {
+	p.watchdog = &opts
+}
+
+func (p *Pool) Run(ctx context.Context, out chan<- domain.Tick) error {
+	p.mu.Lock()
+	p.runCtx = ctx
+	p.out = out
+	p.results = make(chan error)
+	p.watchdogs = p.watchdogs[:0]
+	for _, client := range p.clients {
+		p.start(client)
+	}
+	results := p.results
+	p.mu.Unlock()
+
+	var errs []error
+	for {
+		p.mu.Lock()
+		if p.active == 0 {
+			p.runCtx = nil
+			p.out = nil
+			p.mu.Unlock()
+			return errors.Join(errs...)
+		}
+		p.mu.Unlock()
+
+		err := <-results
+		p.mu.Lock()
+		p.active--
+		p.mu.Unlock()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+}
+
+func (p *Pool) start(client *Client) {
+	var ingestor ingest.Ingestor = client
+	if p.watchdog != nil {
+		opts := *p.watchdog
+		opts.Name = strings.TrimSpace("websocket " + client.name)
+		opts.Expected = client.expectedTokens
+		watchdog := ingest.NewWatchdog(client, opts)
+		p.watchdogs = append(p.watchdogs, watchdog)
+		ingestor = watchdog
+	}
+	ctx, out, results := p.runCtx, p.out, p.results
+	p.active++
+	go func() {
+		results <- ingestor.Run(ctx, out)
+	}()
+}
+
+func (p *Pool) Clients() []*Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*Client(nil), p.clients...)
+}
+
+func (p *Pool) Disconnects() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var total uint64
+	for _, client := range p.clients {
+		total += client.Disconnects()
+	}
+	return total
+}
+
+func (p *Pool) SequenceStats() SequenceStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var total SequenceStats
+	for _, client := range p.clients {
+		stats := client.SequenceStats()
+		total.Gaps += stats.Gaps
+		total.Missing += stats.Missing
+		total.OutOfOrder += stats.OutOfOrder
+	}
+	return total
+}
+
+func (p *Pool) LastPongs() []time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pongs := make([]time.Time, len(p.clients))
+	for i, client := range p.clients {
+		pongs[i] = client.LastPong()
+	}
+	return pongs
+}
+
+func (p *Pool) Stalls() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var total uint64
+	for _, watchdog := range p.watchdogs {
+		total += watchdog.Stalls()
+	}
+	return total
+}
+
+func (p *Pool) TokenStalls() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var total uint64
+	for _, watchdog := range p.watchdogs {
+		total += watchdog.TokenStalls()
+	}
+	return total
+}
+
+func (p *Pool) Subscriptions() []config.WebsocketSubscription {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var subs []config.WebsocketSubscription
+	for _, client := range p.clients {
+		subs = append(subs, client.Subscriptions()...)
+	}
+	return subs
+}
+
+func (p *Pool) Subscribe(subs []config.WebsocketSubscription) error {
+	if err := validateSubscriptions(subs); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	load := make([]int, len(p.clients))
+	for i, client := range p.clients {
+		load[i] = client.subs.size()
+	}
+	routed := make([][]config.WebsocketSubscription, len(p.clients))
+	for _, sub := range subs {
+		for _, token := range sub.Tokens {
+			i := p.owner(sub.ExchangeType, token)
+			if i < 0 {
+				i = leastLoaded(load, p.maxTokens)
+				if i < 0 && len(load) < p.maxConns {
+					load = append(load, 0)
+					routed = append(routed, nil)
+					i = len(load) - 1
+				}
+				if i < 0 {
+					return fmt.Errorf("no websocket connection has room for %d:%s (limit %d tokens on each of %d connections)", sub.ExchangeType, token, p.maxTokens, p.maxConns)
+				}
+				load[i]++
+			}
+			routed[i] = append(routed[i], config.WebsocketSubscription{ExchangeType: sub.ExchangeType, Tokens: []string{token}, Mode: sub.Mode})
+		}
+	}
+
+	for i, shard := range routed {
+		if len(shard) == 0 {
+			continue
+		}
+		if i >= len(p.clients) {
+			client := p.addClient(shard, true)
+			if p.logger != nil {
+				p.logger.Printf("websocket %s opened for %d new tokens", client.name, load[i])
+			}
+			if p.runCtx != nil {
+				p.start(client)
+			}
+			continue
+		}
+		if err := p.clients[i].Subscribe(shard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Pool) Unsubscribe(subs []config.WebsocketSubscription) error {
+	if err := validateSubscriptions(subs); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	routed := make([][]config.WebsocketSubscription, len(p.clients))
+	for _, sub := range subs {
+		for _, token := range sub.Tokens {
+			if i := p.owner(sub.ExchangeType, token); i >= 0 {
+				routed[i] = append(routed[i], config.WebsocketSubscription{ExchangeType: sub.ExchangeType, Tokens: []string{token}})
+			}
+		}
+	}
+
+	for i, shard := range routed {
+		if len(shard) == 0 {
+			continue
+		}
+		if err := p.clients[i].Unsubscribe(shard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Pool) owner(exchangeType int, token string) int {
+	for i, client := range p.clients {
+		if client.subs.has(exchangeType, token) {
+			return i
+		}
+	}
+	return -1
+}
+
+func leastLoaded(load []int, maxTokens int) int {
+	best := -1
+	for i, n := range load {
+		if maxTokens > 0 && n >= maxTokens {
+			continue
+		}
+		if best < 0 || n < load[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+func (p *Pool) Reconnect() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, client := range p.clients {
+		client.Reconnect()
+	}
+}
+
+func (c *Client) expectedTokens() []ingest.TokenKey {
+	var keys []ingest.TokenKey
+	for _, sub := range c.subs.list() {
+		for _, token := range sub.Tokens {
+			keys = append(keys, ingest.TokenKey{ExchangeType: sub.ExchangeType, Token: token})
+		}
+	}
+	return keys
+}
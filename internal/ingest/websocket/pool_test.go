@@ -0,0 +1,230 @@
+package websocket
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/e1/internal/auth"
+	"example.com/e1/internal/config"
+	"example.com/e1/internal/domain"
+	"github.com/gorilla/websocket"
+)
+
+func TestShardSubscriptionsKeepsExchangeGrouping(t *testing.T) {
+	shards := shardSubscriptions([]config.WebsocketSubscription{
+		{ExchangeType: 1, Tokens: []string{"a", "b", "c"}, Mode: 1},
+		{ExchangeType: 2, Tokens: []string{"d", "e"}, Mode: 1},
+	}, 2)
+
+	if len(shards) != 3 {
+		t.Fatalf("expected 3 shards, got %+v", shards)
+	}
+	if len(shards[1]) != 2 || shards[1][0].ExchangeType != 1 || shards[1][0].Tokens[0] != "c" || shards[1][1].ExchangeType != 2 {
+		t.Fatalf("unexpected middle shard: %+v", shards[1])
+	}
+	if len(shards[2]) != 1 || shards[2][0].Tokens[0] != "e" || shards[2][0].Mode != 1 {
+		t.Fatalf("unexpected last shard: %+v", shards[2])
+	}
+}
+
+func TestPoolRunsOneConnectionPerShard(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req streamRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		for _, list := range req.Params.TokenList {
+			for _, token := range list.Tokens {
+				_ = conn.WriteMessage(websocket.BinaryMessage, ltpFrame(token, 100))
+			}
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	cfg := testConfig("ws" + strings.TrimPrefix(server.URL, "http"))
+	cfg.WebsocketTokens = []config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"1", "2", "3"}}}
+	cfg.WebsocketMaxTokensPerConn = 2
+	pool := NewPool(cfg, auth.StaticSession{}, log.New(io.Discard, "", 0))
+	if len(pool.Clients()) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(pool.Clients()))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan domain.Tick, 3)
+	done := make(chan error, 1)
+	go func() { done <- pool.Run(ctx, out) }()
+
+	seen := make(map[string]bool)
+	for len(seen) < 3 {
+		select {
+		case tick := <-out:
+			seen[tick.Token] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("only received %v", seen)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+}
+
+func TestPoolRoutesSubscriptionsByCapacity(t *testing.T) {
+	cfg := testConfig("ws://unused")
+	cfg.WebsocketTokens = []config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"1", "2", "3"}}}
+	cfg.WebsocketMaxTokensPerConn = 2
+	pool := NewPool(cfg, auth.StaticSession{}, log.New(io.Discard, "", 0))
+
+	if err := pool.Subscribe([]config.WebsocketSubscription{{ExchangeType: 2, Tokens: []string{"4"}}}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if !pool.Clients()[1].subs.has(2, "4") {
+		t.Fatal("expected new token on the shard with spare capacity")
+	}
+	if err := pool.Subscribe([]config.WebsocketSubscription{{ExchangeType: 2, Tokens: []string{"5"}}}); err == nil {
+		t.Fatal("expected capacity error")
+	}
+
+	if err := pool.Unsubscribe([]config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"1"}}}); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if pool.Clients()[0].subs.has(1, "1") {
+		t.Fatal("expected token to be removed from its shard")
+	}
+	if len(pool.Subscriptions()) != 3 {
+		t.Fatalf("unexpected subscriptions: %+v", pool.Subscriptions())
+	}
+}
+
+func TestPoolOpensConnectionsUpToLimit(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req streamRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		for _, list := range req.Params.TokenList {
+			for _, token := range list.Tokens {
+				_ = conn.WriteMessage(websocket.BinaryMessage, ltpFrame(token, 100))
+			}
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	cfg := testConfig("ws" + strings.TrimPrefix(server.URL, "http"))
+	cfg.WebsocketTokens = []config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"1", "2"}}}
+	cfg.WebsocketMaxTokensPerConn = 2
+	cfg.WebsocketMaxConnections = 2
+	pool := NewPool(cfg, auth.StaticSession{}, log.New(io.Discard, "", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan domain.Tick, 4)
+	done := make(chan error, 1)
+	go func() { done <- pool.Run(ctx, out) }()
+
+	if err := pool.Subscribe([]config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"3"}}}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if len(pool.Clients()) != 2 {
+		t.Fatalf("expected a second connection, got %d", len(pool.Clients()))
+	}
+	seen := make(map[string]bool)
+	for !seen["3"] {
+		select {
+		case tick := <-out:
+			seen[tick.Token] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no tick from the new connection, saw %v", seen)
+		}
+	}
+
+	if err := pool.Subscribe([]config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"4"}}}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := pool.Subscribe([]config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"5"}}}); err == nil {
+		t.Fatal("expected an error once every connection is full")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+}
+
+func TestPoolReportsLastPongPerConnection(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req streamRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		_ = conn.WriteMessage(websocket.TextMessage, []byte("pong"))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	cfg := testConfig("ws" + strings.TrimPrefix(server.URL, "http"))
+	cfg.WebsocketTokens = []config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"1", "2", "3"}}}
+	cfg.WebsocketMaxTokensPerConn = 2
+	pool := NewPool(cfg, auth.StaticSession{}, log.New(io.Discard, "", 0))
+	if pongs := pool.LastPongs(); len(pongs) != 2 || !pongs[0].IsZero() {
+		t.Fatalf("expected no pongs before connecting, got %v", pongs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pool.Run(ctx, make(chan domain.Tick)) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pongs := pool.LastPongs()
+		if !pongs[0].IsZero() && !pongs[1].IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a pong on every connection, got %v", pongs)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+}
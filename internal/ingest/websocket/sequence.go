@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"sync"
+
+	"example.com/e1/internal/domain"
+)
+
+type SequenceStats struct {
+	Gaps       uint64 `json:"gaps"`
+	Missing    uint64 `json:"missing"`
+	OutOfOrder uint64 `json:"out_of_order"`
+}
+
+type sequenceKey struct {
+	exchangeType int
+	token        string
+}
+
+type sequenceResult struct {
+	previous   int64
+	missing    int64
+	outOfOrder bool
+}
+
+type sequenceTracker struct {
+	mu    sync.Mutex
+	last  map[sequenceKey]int64
+	stats SequenceStats
+}
+
+func newSequenceTracker() *sequenceTracker {
+	return &sequenceTracker{last: make(map[sequenceKey]int64)}
+}
+
+func (t *sequenceTracker) observe(tick domain.Tick) sequenceResult {
+	if tick.SequenceNumber <= 0 {
+		return sequenceResult{}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := sequenceKey{exchangeType: tick.ExchangeType, token: tick.Token}
+	previous, seen := t.last[key]
+	if !seen {
+		t.last[key] = tick.SequenceNumber
+		return sequenceResult{}
+	}
+
+	result := sequenceResult{previous: previous}
+	switch {
+	case tick.SequenceNumber <= previous:
+		result.outOfOrder = true
+		t.stats.OutOfOrder++
+		return result
+	case tick.SequenceNumber > previous+1:
+		result.missing = tick.SequenceNumber - previous - 1
+		t.stats.Gaps++
+		t.stats.Missing += uint64(result.missing)
+	}
+	t.last[key] = tick.SequenceNumber
+	return result
+}
+
+func (t *sequenceTracker) snapshot() SequenceStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
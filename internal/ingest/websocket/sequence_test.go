@@ -0,0 +1,33 @@
+package websocket
+
+import (
+	"testing"
+
+	"example.com/e1/internal/domain"
+)
+
+func TestSequenceTrackerDetectsGapsAndReordering(t *testing.T) {
+	tracker := newSequenceTracker()
+	tick := func(token string, seq int64) domain.Tick {
+		return domain.Tick{Token: token, ExchangeType: 1, SequenceNumber: seq}
+	}
+
+	tracker.observe(tick("2885", 10))
+	tracker.observe(tick("2885", 11))
+	tracker.observe(tick("1594", 500))
+
+	if result := tracker.observe(tick("2885", 15)); result.missing != 3 || result.previous != 11 {
+		t.Fatalf("unexpected gap result: %+v", result)
+	}
+	if result := tracker.observe(tick("2885", 14)); !result.outOfOrder {
+		t.Fatalf("expected out of order result: %+v", result)
+	}
+	if result := tracker.observe(tick("1594", 501)); result.missing != 0 || result.outOfOrder {
+		t.Fatalf("unexpected result for contiguous token: %+v", result)
+	}
+
+	stats := tracker.snapshot()
+	if stats.Gaps != 1 || stats.Missing != 3 || stats.OutOfOrder != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,183 @@
+package websocket
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"example.com/e1/internal/config"
+)
+
+const (
+	actionUnsubscribe = 0
+	actionSubscribe   = 1
+)
+
+type subscriptionKey struct {
+	exchangeType int
+	token        string
+}
+
+type subscriptionSet struct {
+	mu          sync.Mutex
+	defaultMode int
+	modes       map[subscriptionKey]int
+}
+
+func newSubscriptionSet(defaultMode int, subs []config.WebsocketSubscription) *subscriptionSet {
+	set := &subscriptionSet{
+		defaultMode: defaultMode,
+		modes:       make(map[subscriptionKey]int),
+	}
+	set.add(subs)
+	return set
+}
+
+func (s *subscriptionSet) add(subs []config.WebsocketSubscription) (added, replaced []config.WebsocketSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	addedModes := make(map[subscriptionKey]int)
+	replacedModes := make(map[subscriptionKey]int)
+	for _, sub := range subs {
+		mode := sub.Mode
+		if mode == 0 {
+			mode = s.defaultMode
+		}
+		for _, token := range sub.Tokens {
+			key := subscriptionKey{exchangeType: sub.ExchangeType, token: token}
+			current, ok := s.modes[key]
+			if ok && current == mode {
+				continue
+			}
+			if ok {
+				replacedModes[key] = current
+			}
+			s.modes[key] = mode
+			addedModes[key] = mode
+		}
+	}
+	return groupSubscriptions(addedModes), groupSubscriptions(replacedModes)
+}
+
+func (s *subscriptionSet) remove(subs []config.WebsocketSubscription) []config.WebsocketSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removedModes := make(map[subscriptionKey]int)
+	for _, sub := range subs {
+		for _, token := range sub.Tokens {
+			key := subscriptionKey{exchangeType: sub.ExchangeType, token: token}
+			mode, ok := s.modes[key]
+			if !ok {
+				continue
+			}
+			delete(s.modes, key)
+			removedModes[key] = mode
+		}
+	}
+	return groupSubscriptions(removedModes)
+}
+
+func (s *subscriptionSet) has(exchangeType int, token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.modes[subscriptionKey{exchangeType: exchangeType, token: token}]
+	return ok
+}
+
+func (s *subscriptionSet) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.modes)
+}
+
+func (s *subscriptionSet) list() []config.WebsocketSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return groupSubscriptions(s.modes)
+}
+
+func groupSubscriptions(modes map[subscriptionKey]int) []config.WebsocketSubscription {
+	type groupKey struct {
+		mode         int
+		exchangeType int
+	}
+	groups := make(map[groupKey][]string)
+	for key, mode := range modes {
+		group := groupKey{mode: mode, exchangeType: key.exchangeType}
+		groups[group] = append(groups[group], key.token)
+	}
+
+	keys := make([]groupKey, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].mode != keys[j].mode {
+			return keys[i].mode < keys[j].mode
+		}
+		return keys[i].exchangeType < keys[j].exchangeType
+	})
+
+	subs := make([]config.WebsocketSubscription, 0, len(keys))
+	for _, key := range keys {
+		tokens := groups[key]
+		sort.Strings(tokens)
+		subs = append(subs, config.WebsocketSubscription{
+			ExchangeType: key.exchangeType,
+			Tokens:       tokens,
+			Mode:         key.mode,
+		})
+	}
+	return subs
+}
+
+func shardSubscriptions(subs []config.WebsocketSubscription, maxTokens int) [][]config.WebsocketSubscription {
+	if maxTokens <= 0 {
+		return [][]config.WebsocketSubscription{subs}
+	}
+
+	shards := [][]config.WebsocketSubscription{nil}
+	used := 0
+	for _, sub := range subs {
+		tokens := sub.Tokens
+		for len(tokens) > 0 {
+			if used == maxTokens {
+				shards = append(shards, nil)
+				used = 0
+			}
+			n := min(maxTokens-used, len(tokens))
+			last := len(shards) - 1
+			shards[last] = append(shards[last], config.WebsocketSubscription{
+				ExchangeType: sub.ExchangeType,
+				Tokens:       append([]string(nil), tokens[:n]...),
+				Mode:         sub.Mode,
+			})
+			used += n
+			tokens = tokens[n:]
+		}
+	}
+	return shards
+}
+
+func validateSubscriptions(subs []config.WebsocketSubscription) error {
+	if len(subs) == 0 {
+		return fmt.Errorf("no subscriptions given")
+	}
+	for _, sub := range subs {
+		if sub.ExchangeType <= 0 {
+			return fmt.Errorf("invalid exchange_type %d", sub.ExchangeType)
+		}
+		if len(sub.Tokens) == 0 {
+			return fmt.Errorf("exchange_type %d has no tokens", sub.ExchangeType)
+		}
+		if sub.Mode != 0 && !config.ValidWebsocketMode(sub.Mode) {
+			return fmt.Errorf("exchange_type %d has invalid mode %d", sub.ExchangeType, sub.Mode)
+		}
+		if err := config.ValidateModeExchange(sub.Mode, sub.ExchangeType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
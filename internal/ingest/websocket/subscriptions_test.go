@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+
+	"example.com/e1/internal/config"
+)
+
+func TestBuildRequestsGroupsByMode(t *testing.T) {
+	set := newSubscriptionSet(config.ModeLTP, []config.WebsocketSubscription{
+		{ExchangeType: 2, Tokens: []string{"35001", "35002"}, Mode: config.ModeSnapQuote},
+		{ExchangeType: 1, Tokens: []string{"2885", "1594"}},
+		{ExchangeType: 3, Tokens: []string{"500325"}},
+	})
+
+	reqs := buildRequests(actionSubscribe, set.list())
+	if len(reqs) != 2 {
+		t.Fatalf("expected one request per mode, got %+v", reqs)
+	}
+	if reqs[0].Params.Mode != config.ModeLTP || len(reqs[0].Params.TokenList) != 2 {
+		t.Fatalf("unexpected LTP request: %+v", reqs[0])
+	}
+	if got := reqs[0].Params.TokenList[0].Tokens; len(got) != 2 || got[0] != "1594" {
+		t.Fatalf("unexpected LTP tokens: %v", got)
+	}
+	if reqs[1].Params.Mode != config.ModeSnapQuote || reqs[1].Params.TokenList[0].ExchangeType != 2 {
+		t.Fatalf("unexpected SnapQuote request: %+v", reqs[1])
+	}
+}
+
+func TestMarshalRequestNeedsSingleMode(t *testing.T) {
+	cfg := config.Config{
+		WebsocketMode:   config.ModeLTP,
+		WebsocketTokens: []config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"2885"}}},
+	}
+	payload, err := MarshalRequest(cfg)
+	if err != nil || !strings.Contains(string(payload), `"tokens":["2885"]`) {
+		t.Fatalf("MarshalRequest() = %s, %v", payload, err)
+	}
+
+	cfg.WebsocketTokens = append(cfg.WebsocketTokens, config.WebsocketSubscription{ExchangeType: 2, Tokens: []string{"35001"}, Mode: config.ModeQuote})
+	if _, err := MarshalRequest(cfg); err == nil {
+		t.Fatal("expected an error when subscriptions span several modes")
+	}
+}
+
+func TestSubscriptionSetReportsModeChanges(t *testing.T) {
+	set := newSubscriptionSet(config.ModeLTP, []config.WebsocketSubscription{
+		{ExchangeType: 1, Tokens: []string{"2885"}},
+	})
+
+	added, replaced := set.add([]config.WebsocketSubscription{
+		{ExchangeType: 1, Tokens: []string{"2885"}, Mode: config.ModeQuote},
+	})
+	if len(added) != 1 || added[0].Mode != config.ModeQuote {
+		t.Fatalf("unexpected added: %+v", added)
+	}
+	if len(replaced) != 1 || replaced[0].Mode != config.ModeLTP {
+		t.Fatalf("unexpected replaced: %+v", replaced)
+	}
+
+	if added, _ := set.add([]config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"2885"}, Mode: config.ModeQuote}}); len(added) != 0 {
+		t.Fatalf("expected no-op for existing subscription, got %+v", added)
+	}
+
+	removed := set.remove([]config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"2885"}}})
+	if len(removed) != 1 || removed[0].Mode != config.ModeQuote {
+		t.Fatalf("unexpected removed: %+v", removed)
+	}
+	if len(set.list()) != 0 {
+		t.Fatalf("expected empty set, got %+v", set.list())
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"math/rand/v2"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"example.com/e1/internal/auth"
@@ -17,12 +21,24 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+const minStableConnection = 30 * time.Second
+
 type Client struct {
+	name    string
 	cfg     config.Config
-	session auth.Session
+	session auth.SessionProvider
 	logger  *log.Logger
 	now     func() time.Time
 	dialer  *websocket.Dialer
+	capture *CaptureWriter
+
+	subs        *subscriptionSet
+	disconnects atomic.Uint64
+	lastPong    atomic.Int64
+	sequences   *sequenceTracker
+
+	mu   sync.Mutex
+	conn *websocket.Conn
 }
 
 type streamRequest struct {
@@ -37,90 +53,320 @@ type streamRequest struct {
 	} `json:"params"`
 }
 
-func New(cfg config.Config, session auth.Session, logger *log.Logger) *Client {
+func New(cfg config.Config, session auth.SessionProvider, logger *log.Logger) *Client {
 	return &Client{
-		cfg:     cfg,
-		session: session,
-		logger:  logger,
-		now:     time.Now,
-		dialer:  websocket.DefaultDialer,
+		cfg:       cfg,
+		session:   session,
+		logger:    logger,
+		now:       time.Now,
+		dialer:    websocket.DefaultDialer,
+		subs:      newSubscriptionSet(cfg.WebsocketMode, cfg.WebsocketTokens),
+		sequences: newSequenceTracker(),
 	}
 }
 
 func (c *Client) Run(ctx context.Context, out chan<- domain.Tick) error {
-	headers := http.Header{}
-	headers.Set("Authorization", c.session.JWTToken)
-	headers.Set("x-api-key", c.session.APIKey)
-	headers.Set("x-client-code", c.session.ClientID)
-	headers.Set("x-feed-token", c.session.FeedToken)
+	attempt := 0
+	refreshed := false
+	for {
+		started := c.now()
+		connected, ticked, err := c.runConnection(ctx, out)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, ErrAuthFailed) {
+			if refreshed && !connected {
+				c.logf("ERROR websocket auth still rejected after a session refresh, retrying in %s: %v", c.cfg.ReconnectMaxDelay, err)
+				refreshed = false
+				if !wait(ctx, c.cfg.ReconnectMaxDelay) {
+					return nil
+				}
+				continue
+			}
+			c.logf("websocket auth rejected, refreshing session: %v", err)
+			if _, refreshErr := c.session.Refresh(ctx); refreshErr != nil {
+				c.logf("ERROR websocket session refresh failed, retrying in %s: %v (refresh: %v)", c.cfg.ReconnectMaxDelay, err, refreshErr)
+				if !wait(ctx, c.cfg.ReconnectMaxDelay) {
+					return nil
+				}
+				continue
+			}
+			refreshed = true
+			if !connected {
+				continue
+			}
+		} else {
+			refreshed = false
+		}
+		if connected {
+			if ticked || c.now().Sub(started) >= minStableConnection {
+				attempt = 0
+			}
+			total := c.disconnects.Add(1)
+			c.logf("websocket disconnected (total %d): %v", total, err)
+		} else {
+			c.logf("websocket connect failed: %v", err)
+		}
+
+		delay := backoffDelay(c.cfg.ReconnectMinDelay, c.cfg.ReconnectMaxDelay, attempt)
+		attempt++
+		c.logf("websocket reconnecting in %s (attempt %d)", delay, attempt)
+		if !wait(ctx, delay) {
+			return nil
+		}
+	}
+}
 
-	conn, _, err := c.dialer.DialContext(ctx, c.cfg.WebsocketURL, headers)
+func wait(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (c *Client) Disconnects() uint64 {
+	return c.disconnects.Load()
+}
+
+func (c *Client) SequenceStats() SequenceStats {
+	return c.sequences.snapshot()
+}
+
+func (c *Client) runConnection(ctx context.Context, out chan<- domain.Tick) (bool, bool, error) {
+	conn, resp, err := c.dialer.DialContext(ctx, c.cfg.WebsocketURL, c.headers())
 	if err != nil {
-		return fmt.Errorf("dial websocket: %w", err)
+		return false, false, handshakeError(resp, err)
 	}
 	defer conn.Close()
 
-	if err := conn.WriteJSON(buildRequest(c.cfg)); err != nil {
-		return fmt.Errorf("subscribe websocket: %w", err)
+	if err := c.attach(conn); err != nil {
+		return false, false, fmt.Errorf("subscribe websocket: %w", err)
 	}
+	defer c.detach(conn)
 
-	pingTicker := time.NewTicker(c.cfg.WebsocketPingPeriod)
-	defer pingTicker.Stop()
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	go func() {
+		pingTicker := time.NewTicker(c.cfg.WebsocketPingPeriod)
+		defer pingTicker.Stop()
 		for {
 			select {
-			case <-ctx.Done():
+			case <-connCtx.Done():
+				if ctx.Err() != nil {
+					_ = c.write(func() error {
+						return conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+					})
+					_ = conn.Close()
+				}
 				return
 			case <-pingTicker.C:
-				if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil && c.logger != nil {
-					c.logger.Printf("websocket ping failed: %v", err)
+				if err := c.write(func() error { return conn.WriteMessage(websocket.TextMessage, []byte("ping")) }); err != nil {
+					c.logf("websocket ping failed: %v", err)
 				}
 			}
 		}
 	}()
 
+	ticked := false
 	for {
-		select {
-		case <-ctx.Done():
-			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-			return nil
-		default:
-		}
-
 		messageType, payload, err := conn.ReadMessage()
 		if err != nil {
-			if ctx.Err() != nil {
-				return nil
+			return true, ticked, fmt.Errorf("read websocket message: %w", err)
+		}
+		if messageType == websocket.TextMessage {
+			if err := c.handleText(payload); err != nil {
+				return true, ticked, err
 			}
-			return fmt.Errorf("read websocket message: %w", err)
+			continue
 		}
 		if messageType != websocket.BinaryMessage {
 			continue
 		}
+		if c.capture != nil {
+			if err := c.capture.Write(c.now(), payload); err != nil {
+				c.logf("capture websocket frame: %v", err)
+			}
+		}
 
 		tick, err := ParseBinaryTick(payload, c.now)
 		if err != nil {
-			if c.logger != nil {
-				c.logger.Printf("parse websocket payload: %v", err)
-			}
+			c.logf("parse websocket payload: %v", err)
 			continue
 		}
 		if tick == nil {
 			continue
 		}
-		out <- *tick
+		c.checkSequence(*tick)
+		ticked = true
+		select {
+		case out <- *tick:
+		case <-ctx.Done():
+			return true, ticked, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) SetCapture(capture *CaptureWriter) {
+	c.capture = capture
+}
+
+func (c *Client) Reconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+}
+
+func (c *Client) LastPong() time.Time {
+	nanos := c.lastPong.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos).UTC()
+}
+
+func (c *Client) handleText(payload []byte) error {
+	event := parseTextFrame(payload)
+	switch event.kind {
+	case textEventPong:
+		c.lastPong.Store(c.now().UnixNano())
+	case textEventError:
+		c.logf("websocket server error code=%q correlation_id=%q fatal=%t message=%q", event.err.Code, event.err.CorrelationID, event.err.Fatal(), event.err.Message)
+		if event.err.Fatal() {
+			return event.err
+		}
+	default:
+		c.logf("websocket text frame: %s", event.raw)
 	}
+	return nil
 }
 
-func buildRequest(cfg config.Config) streamRequest {
-	req := streamRequest{
-		CorrelationID: "live-ingestor",
-		Action:        1,
+func (c *Client) Subscribe(subs []config.WebsocketSubscription) error {
+	if err := validateSubscriptions(subs); err != nil {
+		return err
 	}
-	req.Params.Mode = cfg.WebsocketMode
-	for _, sub := range cfg.WebsocketTokens {
-		req.Params.TokenList = append(req.Params.TokenList, struct {
+	added, replaced := c.subs.add(subs)
+	if err := c.send(buildRequests(actionUnsubscribe, replaced)); err != nil {
+		return err
+	}
+	return c.send(buildRequests(actionSubscribe, added))
+}
+
+func (c *Client) Unsubscribe(subs []config.WebsocketSubscription) error {
+	if err := validateSubscriptions(subs); err != nil {
+		return err
+	}
+	return c.send(buildRequests(actionUnsubscribe, c.subs.remove(subs)))
+}
+
+func (c *Client) Subscriptions() []config.WebsocketSubscription {
+	return c.subs.list()
+}
+
+func (c *Client) attach(conn *websocket.Conn) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, req := range buildRequests(actionSubscribe, c.subs.list()) {
+		if err := conn.WriteJSON(req); err != nil {
+			return err
+		}
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *Client) detach(conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+}
+
+func (c *Client) send(reqs []streamRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	for _, req := range reqs {
+		if err := c.conn.WriteJSON(req); err != nil {
+			return fmt.Errorf("send websocket request: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) write(fn func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fn()
+}
+
+func (c *Client) checkSequence(tick domain.Tick) {
+	result := c.sequences.observe(tick)
+	switch {
+	case result.outOfOrder:
+		c.logf("websocket sequence out of order for %d:%s: got %d after %d", tick.ExchangeType, tick.Token, tick.SequenceNumber, result.previous)
+	case result.missing > 0:
+		c.logf("websocket sequence gap for %d:%s: %d missing between %d and %d", tick.ExchangeType, tick.Token, result.missing, result.previous, tick.SequenceNumber)
+	}
+}
+
+func (c *Client) headers() http.Header {
+	session := c.session.Current()
+	headers := http.Header{}
+	headers.Set("Authorization", session.JWTToken)
+	headers.Set("x-api-key", session.APIKey)
+	headers.Set("x-client-code", session.ClientID)
+	headers.Set("x-feed-token", session.FeedToken)
+	return headers
+}
+
+func (c *Client) logf(format string, args ...any) {
+	if c.logger == nil {
+		return
+	}
+	if c.name != "" {
+		format = c.name + " " + format
+	}
+	c.logger.Printf(format, args...)
+}
+
+func backoffDelay(minDelay, maxDelay time.Duration, attempt int) time.Duration {
+	delay := minDelay
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(delay-half)+1))
+}
+
+func buildRequests(action int, subs []config.WebsocketSubscription) []streamRequest {
+	var reqs []streamRequest
+	index := make(map[int]int)
+	for _, sub := range subs {
+		i, ok := index[sub.Mode]
+		if !ok {
+			req := streamRequest{
+				CorrelationID: "live-ingestor",
+				Action:        action,
+			}
+			req.Params.Mode = sub.Mode
+			reqs = append(reqs, req)
+			i = len(reqs) - 1
+			index[sub.Mode] = i
+		}
+		reqs[i].Params.TokenList = append(reqs[i].Params.TokenList, struct {
 			ExchangeType int      `json:"exchangeType"`
 			Tokens       []string `json:"tokens"`
 		}{
@@ -128,7 +374,7 @@ func buildRequest(cfg config.Config) streamRequest {
 			Tokens:       append([]string(nil), sub.Tokens...),
 		})
 	}
-	return req
+	return reqs
 }
 
 func ParseBinaryTick(data []byte, now func() time.Time) (*domain.Tick, error) {
@@ -155,6 +401,11 @@ func ParseBinaryTick(data []byte, now func() time.Time) (*domain.Tick, error) {
 			return nil, fmt.Errorf("invalid snap quote packet size: %d", len(data))
 		}
 		return parseSnapQuotePacket(data, now), nil
+	case 4:
+		if len(data) != 443 {
+			return nil, fmt.Errorf("invalid depth 20 packet size: %d", len(data))
+		}
+		return parseDepth20Packet(data, now), nil
 	default:
 		return nil, fmt.Errorf("unknown subscription mode: %d", data[0])
 	}
@@ -164,12 +415,13 @@ func parseLTPPacket(data []byte, now func() time.Time) *domain.Tick {
 	exchangeType := int(data[1])
 	divisor := priceDivisor(exchangeType)
 	return &domain.Tick{
-		Source:       domain.SourceWebsocket,
-		Token:        string(bytes.Trim(data[2:27], "\x00")),
-		ExchangeType: exchangeType,
-		EventTime:    time.UnixMilli(int64(binary.LittleEndian.Uint64(data[35:43]))),
-		ReceivedAt:   now().UTC(),
-		LTP:          float64(int64(binary.LittleEndian.Uint64(data[43:51]))) / divisor,
+		Source:         domain.SourceWebsocket,
+		Token:          string(bytes.Trim(data[2:27], "\x00")),
+		ExchangeType:   exchangeType,
+		SequenceNumber: int64(binary.LittleEndian.Uint64(data[27:35])),
+		EventTime:      time.UnixMilli(int64(binary.LittleEndian.Uint64(data[35:43]))),
+		ReceivedAt:     now().UTC(),
+		LTP:            float64(int64(binary.LittleEndian.Uint64(data[43:51]))) / divisor,
 	}
 }
 
@@ -180,6 +432,7 @@ func parseQuotePacket(data []byte, now func() time.Time) *domain.Tick {
 		Source:         domain.SourceWebsocket,
 		Token:          string(bytes.Trim(data[2:27], "\x00")),
 		ExchangeType:   exchangeType,
+		SequenceNumber: int64(binary.LittleEndian.Uint64(data[27:35])),
 		EventTime:      time.UnixMilli(int64(binary.LittleEndian.Uint64(data[35:43]))),
 		ReceivedAt:     now().UTC(),
 		LTP:            float64(int64(binary.LittleEndian.Uint64(data[43:51]))) / divisor,
@@ -198,6 +451,12 @@ func parseQuotePacket(data []byte, now func() time.Time) *domain.Tick {
 func parseSnapQuotePacket(data []byte, now func() time.Time) *domain.Tick {
 	tick := parseQuotePacket(data[:123], now)
 	divisor := priceDivisor(int(data[1]))
+	if lastTraded := int64(binary.LittleEndian.Uint64(data[123:131])); lastTraded > 0 {
+		tick.LastTradedTime = time.Unix(lastTraded, 0).UTC()
+	}
+	tick.OpenInterest = int64(binary.LittleEndian.Uint64(data[131:139]))
+	tick.OIChangePct = math.Float64frombits(binary.LittleEndian.Uint64(data[139:147]))
+	tick.Depth = parseBestFive(data[147:347], divisor)
 	tick.UpperCircuit = float64(int64(binary.LittleEndian.Uint64(data[347:355]))) / divisor
 	tick.LowerCircuit = float64(int64(binary.LittleEndian.Uint64(data[355:363]))) / divisor
 	tick.High52Week = float64(int64(binary.LittleEndian.Uint64(data[363:371]))) / divisor
@@ -205,6 +464,58 @@ func parseSnapQuotePacket(data []byte, now func() time.Time) *domain.Tick {
 	return tick
 }
 
+func parseBestFive(data []byte, divisor float64) *domain.Depth {
+	depth := &domain.Depth{
+		Bids: make([]domain.DepthLevel, 0, 5),
+		Asks: make([]domain.DepthLevel, 0, 5),
+	}
+	for offset := 0; offset+20 <= len(data); offset += 20 {
+		packet := data[offset : offset+20]
+		level := domain.DepthLevel{
+			Quantity: int64(binary.LittleEndian.Uint64(packet[2:10])),
+			Price:    float64(int64(binary.LittleEndian.Uint64(packet[10:18]))) / divisor,
+			Orders:   int(binary.LittleEndian.Uint16(packet[18:20])),
+		}
+		if binary.LittleEndian.Uint16(packet[0:2]) == 1 {
+			depth.Bids = append(depth.Bids, level)
+		} else {
+			depth.Asks = append(depth.Asks, level)
+		}
+	}
+	return depth
+}
+
+func parseDepth20Packet(data []byte, now func() time.Time) *domain.Tick {
+	exchangeType := int(data[1])
+	divisor := priceDivisor(exchangeType)
+	depth := &domain.Depth{
+		Bids: parseDepth20Side(data[43:243], divisor),
+		Asks: parseDepth20Side(data[243:443], divisor),
+	}
+	return &domain.Tick{
+		Source:       domain.SourceWebsocket,
+		Token:        string(bytes.Trim(data[2:27], "\x00")),
+		ExchangeType: exchangeType,
+		EventTime:    time.UnixMilli(int64(binary.LittleEndian.Uint64(data[35:43]))),
+		ReceivedAt:   now().UTC(),
+		Depth:        depth,
+		DepthOnly:    true,
+	}
+}
+
+func parseDepth20Side(data []byte, divisor float64) []domain.DepthLevel {
+	levels := make([]domain.DepthLevel, 0, 20)
+	for offset := 0; offset+10 <= len(data); offset += 10 {
+		packet := data[offset : offset+10]
+		levels = append(levels, domain.DepthLevel{
+			Quantity: int64(int32(binary.LittleEndian.Uint32(packet[0:4]))),
+			Price:    float64(int32(binary.LittleEndian.Uint32(packet[4:8]))) / divisor,
+			Orders:   int(int16(binary.LittleEndian.Uint16(packet[8:10]))),
+		})
+	}
+	return levels
+}
+
 func priceDivisor(exchangeType int) float64 {
 	if exchangeType == 13 {
 		return 10000000.0
@@ -213,5 +524,25 @@ func priceDivisor(exchangeType int) float64 {
 }
 
 func MarshalRequest(cfg config.Config) ([]byte, error) {
-	return json.Marshal(buildRequest(cfg))
+	payloads, err := MarshalRequests(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(payloads) != 1 {
+		return nil, fmt.Errorf("subscriptions need %d requests, use MarshalRequests", len(payloads))
+	}
+	return payloads[0], nil
+}
+
+func MarshalRequests(cfg config.Config) ([][]byte, error) {
+	reqs := buildRequests(actionSubscribe, newSubscriptionSet(cfg.WebsocketMode, cfg.WebsocketTokens).list())
+	payloads := make([][]byte, 0, len(reqs))
+	for _, req := range reqs {
+		payload, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads, nil
 }
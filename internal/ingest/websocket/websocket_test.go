@@ -0,0 +1,384 @@
+package websocket
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/e1/internal/auth"
+	"example.com/e1/internal/config"
+	"example.com/e1/internal/domain"
+	"github.com/gorilla/websocket"
+)
+
+func ltpFrame(token string, ltp uint64) []byte {
+	payload := make([]byte, 51)
+	payload[0] = 1
+	payload[1] = 1
+	copy(payload[2:], []byte(token))
+	binary.LittleEndian.PutUint64(payload[35:43], uint64(1710000000000))
+	binary.LittleEndian.PutUint64(payload[43:51], ltp)
+	return payload
+}
+
+func testConfig(url string) config.Config {
+	return config.Config{
+		WebsocketURL:        url,
+		WebsocketMode:       1,
+		WebsocketTokens:     []config.WebsocketSubscription{{ExchangeType: 1, Tokens: []string{"2885"}}},
+		WebsocketPingPeriod: time.Hour,
+		ReconnectMinDelay:   time.Millisecond,
+		ReconnectMaxDelay:   5 * time.Millisecond,
+	}
+}
+
+func TestClientReconnectsAndResubscribes(t *testing.T) {
+	var connections atomic.Int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-feed-token") != "feed" {
+			t.Errorf("missing feed token header on dial %d", connections.Load()+1)
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var req streamRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		if req.Action != 1 || len(req.Params.TokenList) != 1 || req.Params.TokenList[0].Tokens[0] != "2885" {
+			t.Errorf("unexpected subscription: %+v", req)
+		}
+		if connections.Add(1) == 1 {
+			return
+		}
+		_ = conn.WriteMessage(websocket.BinaryMessage, ltpFrame("2885", 15025))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := New(testConfig("ws"+strings.TrimPrefix(server.URL, "http")), auth.StaticSession{FeedToken: "feed"}, log.New(io.Discard, "", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan domain.Tick, 1)
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx, out) }()
+
+	select {
+	case tick := <-out:
+		if tick.Token != "2885" || tick.LTP != 150.25 {
+			t.Fatalf("unexpected tick: %+v", tick)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no tick after reconnect")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := connections.Load(); got != 2 {
+		t.Fatalf("expected 2 connections, got %d", got)
+	}
+	if got := client.Disconnects(); got != 1 {
+		t.Fatalf("expected 1 disconnect, got %d", got)
+	}
+}
+
+func TestClientKeepsBackingOffWhenConnectionsDropImmediately(t *testing.T) {
+	var connections atomic.Int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req streamRequest
+		_ = conn.ReadJSON(&req)
+		connections.Add(1)
+	}))
+	defer server.Close()
+
+	attempts := make(chan string, 16)
+	logger := log.New(writerFunc(func(p []byte) (int, error) {
+		if line := string(p); strings.Contains(line, "reconnecting in") {
+			select {
+			case attempts <- line:
+			default:
+			}
+		}
+		return len(p), nil
+	}), "", 0)
+	client := New(testConfig("ws"+strings.TrimPrefix(server.URL, "http")), auth.StaticSession{}, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx, make(chan domain.Tick)) }()
+
+	var last string
+	for i := 0; i < 3; i++ {
+		select {
+		case last = <-attempts:
+		case <-time.After(2 * time.Second):
+			t.Fatal("client did not reconnect")
+		}
+	}
+	cancel()
+	<-done
+	if !strings.Contains(last, "(attempt 3)") {
+		t.Fatalf("expected backoff to keep growing across short-lived connections, got %q", last)
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestBackoffDelayIsBounded(t *testing.T) {
+	for attempt := 0; attempt < 20; attempt++ {
+		delay := backoffDelay(time.Second, 30*time.Second, attempt)
+		if delay < 500*time.Millisecond || delay > 30*time.Second {
+			t.Fatalf("attempt %d: delay %s out of range", attempt, delay)
+		}
+	}
+	if delay := backoffDelay(time.Second, time.Minute, 3); delay < 4*time.Second {
+		t.Fatalf("expected exponential growth, got %s", delay)
+	}
+}
+
+func TestClientSubscribesAndUnsubscribesOnLiveConnection(t *testing.T) {
+	requests := make(chan streamRequest, 4)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var req streamRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			requests <- req
+		}
+	}))
+	defer server.Close()
+
+	client := New(testConfig("ws"+strings.TrimPrefix(server.URL, "http")), auth.StaticSession{}, log.New(io.Discard, "", 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = client.Run(ctx, make(chan domain.Tick)) }()
+
+	next := func() streamRequest {
+		select {
+		case req := <-requests:
+			return req
+		case <-time.After(2 * time.Second):
+			t.Fatal("no request received")
+			return streamRequest{}
+		}
+	}
+
+	if req := next(); req.Action != actionSubscribe {
+		t.Fatalf("expected initial subscribe, got %+v", req)
+	}
+
+	option := []config.WebsocketSubscription{{ExchangeType: 2, Tokens: []string{"43500"}}}
+	if err := client.Subscribe(option); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	req := next()
+	if req.Action != actionSubscribe || req.Params.TokenList[0].ExchangeType != 2 || req.Params.TokenList[0].Tokens[0] != "43500" {
+		t.Fatalf("unexpected subscribe request: %+v", req)
+	}
+
+	if err := client.Unsubscribe(option); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if req := next(); req.Action != actionUnsubscribe || req.Params.TokenList[0].Tokens[0] != "43500" {
+		t.Fatalf("unexpected unsubscribe request: %+v", req)
+	}
+
+	subs := client.Subscriptions()
+	if len(subs) != 1 || subs[0].ExchangeType != 1 {
+		t.Fatalf("unexpected subscriptions after unsubscribe: %+v", subs)
+	}
+}
+
+func TestClientRecordsPongAndKeepsRetryingOnAuthError(t *testing.T) {
+	var connections atomic.Int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connections.Add(1)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req streamRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		_ = conn.WriteMessage(websocket.TextMessage, []byte("pong"))
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"errorCode":"E1005","errorMessage":"Invalid Auth token"}`))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := New(testConfig("ws"+strings.TrimPrefix(server.URL, "http")), auth.StaticSession{}, log.New(io.Discard, "", 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx, make(chan domain.Tick)) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for connections.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the client to keep reconnecting, got %d connections", connections.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if client.LastPong().IsZero() {
+		t.Fatal("expected pong to update liveness timestamp")
+	}
+}
+
+func TestClientKeepsRetryingRejectedHandshake(t *testing.T) {
+	var rejections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rejections.Add(1)
+		w.Header().Set("x-error-message", "Invalid Feed Token")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	session := &rotatingSession{session: auth.Session{FeedToken: "stale-feed"}}
+	logged := make(chan string, 16)
+	logger := log.New(writerFunc(func(p []byte) (int, error) {
+		select {
+		case logged <- string(p):
+		default:
+		}
+		return len(p), nil
+	}), "", 0)
+	client := New(testConfig("ws"+strings.TrimPrefix(server.URL, "http")), session, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx, make(chan domain.Tick)) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for rejections.Load() < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected handshakes to be retried, got %d", rejections.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	sawError := false
+	for len(logged) > 0 {
+		if line := <-logged; strings.Contains(line, "ERROR websocket auth still rejected") && strings.Contains(line, "Invalid Feed Token") {
+			sawError = true
+		}
+	}
+	if !sawError {
+		t.Fatal("expected the repeated rejection to be logged as an error")
+	}
+}
+
+type rotatingSession struct {
+	mu        sync.Mutex
+	session   auth.Session
+	refreshes int
+}
+
+func (s *rotatingSession) Current() auth.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session
+}
+
+func (s *rotatingSession) Refresh(context.Context) (auth.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshes++
+	s.session.FeedToken = "fresh-feed"
+	return s.session, nil
+}
+
+func TestClientRefreshesSessionOnRejectedHandshake(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-feed-token") != "fresh-feed" {
+			w.Header().Set("x-error-message", "Invalid Feed Token")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req streamRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		_ = conn.WriteMessage(websocket.BinaryMessage, ltpFrame("2885", 12345))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	session := &rotatingSession{session: auth.Session{FeedToken: "stale-feed"}}
+	client := New(testConfig("ws"+strings.TrimPrefix(server.URL, "http")), session, log.New(io.Discard, "", 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan domain.Tick, 1)
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx, out) }()
+
+	select {
+	case <-out:
+	case err := <-done:
+		t.Fatalf("Run() returned before delivering a tick: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for tick after refresh")
+	}
+	cancel()
+	<-done
+	if session.refreshes != 1 {
+		t.Fatalf("expected one refresh, got %d", session.refreshes)
+	}
+}
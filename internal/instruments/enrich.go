@@ -0,0 +1,52 @@
+package instruments
+
+import (
+	"sync"
+
+	"example.com/e1/internal/domain"
+)
+
+type Enricher struct {
+	mu     sync.RWMutex
+	master *Master
+}
+
+func NewEnricher(master *Master) *Enricher {
+	return &Enricher{master: master}
+}
+
+func (e *Enricher) SetMaster(master *Master) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.master = master
+}
+
+func (e *Enricher) Master() *Master {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.master
+}
+
+func (e *Enricher) Enrich(tick *domain.Tick) {
+	master := e.Master()
+	if master == nil || tick.Token == "" {
+		return
+	}
+	exchange := tick.Exchange
+	if exchange == "" {
+		exchange, _ = ExchangeName(tick.ExchangeType)
+	}
+	instrument, ok := master.ByToken(exchange, tick.Token)
+	if !ok {
+		return
+	}
+	tick.Exchange = instrument.Exchange
+	if tick.ExchangeType == 0 {
+		tick.ExchangeType, _ = ExchangeType(instrument.Exchange)
+	}
+	if tick.TradingSymbol == "" {
+		tick.TradingSymbol = instrument.Symbol
+	}
+	tick.LotSize = instrument.LotSize
+	tick.Expiry = instrument.Expiry
+}
@@ -0,0 +1,37 @@
+package instruments
+
+import (
+	"testing"
+	"time"
+
+	"example.com/e1/internal/domain"
+)
+
+func TestEnricherFillsSymbolFromExchangeType(t *testing.T) {
+	master, err := Load(fixture)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	enricher := NewEnricher(master)
+
+	tick := domain.Tick{Token: "35005", ExchangeType: 2}
+	enricher.Enrich(&tick)
+	if tick.TradingSymbol != "NIFTY28NOV2424500CE" || tick.Exchange != "NFO" || tick.LotSize != 25 {
+		t.Fatalf("unexpected enriched tick: %+v", tick)
+	}
+	if !tick.Expiry.Equal(time.Date(2024, 11, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expiry = %s", tick.Expiry)
+	}
+
+	polled := domain.Tick{Token: "2885", Exchange: "NSE", TradingSymbol: "RELIANCE-EQ"}
+	enricher.Enrich(&polled)
+	if polled.ExchangeType != 1 || polled.LotSize != 1 {
+		t.Fatalf("unexpected enriched poller tick: %+v", polled)
+	}
+
+	unknown := domain.Tick{Token: "999", ExchangeType: 1}
+	enricher.Enrich(&unknown)
+	if unknown.TradingSymbol != "" || unknown.Exchange != "" {
+		t.Fatalf("unknown token should be left alone: %+v", unknown)
+	}
+}
@@ -0,0 +1,27 @@
+package instruments
+
+import "strings"
+
+var exchangeTypes = map[string]int{
+	"NSE": 1,
+	"NFO": 2,
+	"BSE": 3,
+	"BFO": 4,
+	"MCX": 5,
+	"NCX": 7,
+	"CDS": 13,
+}
+
+func ExchangeType(exchange string) (int, bool) {
+	exchangeType, ok := exchangeTypes[strings.ToUpper(exchange)]
+	return exchangeType, ok
+}
+
+func ExchangeName(exchangeType int) (string, bool) {
+	for name, value := range exchangeTypes {
+		if value == exchangeType {
+			return name, true
+		}
+	}
+	return "", false
+}
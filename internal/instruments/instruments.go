@@ -0,0 +1,273 @@
+package instruments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultURL = "https://margincalculator.angelbroking.com/OpenAPI_File/files/OpenAPIScripMaster.json"
+
+const expiryLayout = "02Jan2006"
+
+type Instrument struct {
+	Token          string
+	Symbol         string
+	Name           string
+	Expiry         time.Time
+	Strike         float64
+	LotSize        int64
+	TickSize       float64
+	Exchange       string
+	InstrumentType string
+}
+
+func (i Instrument) OptionType() string {
+	switch {
+	case strings.HasPrefix(i.InstrumentType, "OPT") && strings.HasSuffix(i.Symbol, "CE"):
+		return "CE"
+	case strings.HasPrefix(i.InstrumentType, "OPT") && strings.HasSuffix(i.Symbol, "PE"):
+		return "PE"
+	case strings.HasPrefix(i.InstrumentType, "FUT"):
+		return "FUT"
+	default:
+		return ""
+	}
+}
+
+type rawInstrument struct {
+	Token          string `json:"token"`
+	Symbol         string `json:"symbol"`
+	Name           string `json:"name"`
+	Expiry         string `json:"expiry"`
+	Strike         string `json:"strike"`
+	LotSize        string `json:"lotsize"`
+	InstrumentType string `json:"instrumenttype"`
+	ExchSeg        string `json:"exch_seg"`
+	TickSize       string `json:"tick_size"`
+}
+
+type symbolKey struct {
+	exchange string
+	value    string
+}
+
+type contractKey struct {
+	underlying string
+	expiry     string
+	strike     int64
+	kind       string
+}
+
+type Master struct {
+	instruments []Instrument
+	byToken     map[symbolKey]int
+	bySymbol    map[symbolKey]int
+	byContract  map[contractKey]int
+	expiries    map[string][]time.Time
+	strikes     map[contractKey][]float64
+}
+
+func New(instruments []Instrument) *Master {
+	m := &Master{
+		instruments: instruments,
+		byToken:     make(map[symbolKey]int, len(instruments)),
+		bySymbol:    make(map[symbolKey]int, len(instruments)),
+		byContract:  make(map[contractKey]int),
+		expiries:    make(map[string][]time.Time),
+		strikes:     make(map[contractKey][]float64),
+	}
+	seenExpiry := make(map[contractKey]bool)
+	seenStrike := make(map[contractKey]bool)
+	for i, instrument := range instruments {
+		m.byToken[symbolKey{exchange: instrument.Exchange, value: instrument.Token}] = i
+		m.bySymbol[symbolKey{exchange: instrument.Exchange, value: strings.ToUpper(instrument.Symbol)}] = i
+
+		kind := instrument.OptionType()
+		if kind == "" || instrument.Expiry.IsZero() {
+			continue
+		}
+		key := newContractKey(instrument.Name, instrument.Expiry, instrument.Strike, kind)
+		if _, exists := m.byContract[key]; !exists {
+			m.byContract[key] = i
+		}
+		expiryKey := contractKey{underlying: key.underlying, expiry: key.expiry}
+		strikeKey := contractKey{underlying: key.underlying, expiry: key.expiry, strike: key.strike}
+		if kind != "FUT" && !seenStrike[strikeKey] {
+			seenStrike[strikeKey] = true
+			m.strikes[expiryKey] = append(m.strikes[expiryKey], instrument.Strike)
+		}
+		if !seenExpiry[expiryKey] {
+			seenExpiry[expiryKey] = true
+			m.expiries[key.underlying] = append(m.expiries[key.underlying], instrument.Expiry)
+		}
+	}
+	for key := range m.strikes {
+		sort.Float64s(m.strikes[key])
+	}
+	for underlying := range m.expiries {
+		sort.Slice(m.expiries[underlying], func(a, b int) bool {
+			return m.expiries[underlying][a].Before(m.expiries[underlying][b])
+		})
+	}
+	return m
+}
+
+func Parse(r io.Reader) (*Master, error) {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("read scrip master: %w", err)
+	}
+
+	var instruments []Instrument
+	for dec.More() {
+		var raw rawInstrument
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("decode scrip master entry %d: %w", len(instruments), err)
+		}
+		instrument, err := raw.parse()
+		if err != nil {
+			return nil, fmt.Errorf("scrip master token %s: %w", raw.Token, err)
+		}
+		instruments = append(instruments, instrument)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("read scrip master: %w", err)
+	}
+	return New(instruments), nil
+}
+
+func Load(path string) (*Master, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open scrip master: %w", err)
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+func Download(ctx context.Context, client *http.Client, url string) (*Master, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Minute}
+	}
+	if url == "" {
+		url = DefaultURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create scrip master request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download scrip master: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download scrip master: HTTP %d", resp.StatusCode)
+	}
+	return Parse(resp.Body)
+}
+
+func (m *Master) Len() int {
+	return len(m.instruments)
+}
+
+func (m *Master) All() []Instrument {
+	return m.instruments
+}
+
+func (m *Master) ByToken(exchange, token string) (Instrument, bool) {
+	i, ok := m.byToken[symbolKey{exchange: strings.ToUpper(exchange), value: token}]
+	if !ok {
+		return Instrument{}, false
+	}
+	return m.instruments[i], true
+}
+
+func (m *Master) BySymbol(exchange, symbol string) (Instrument, bool) {
+	i, ok := m.bySymbol[symbolKey{exchange: strings.ToUpper(exchange), value: strings.ToUpper(symbol)}]
+	if !ok {
+		return Instrument{}, false
+	}
+	return m.instruments[i], true
+}
+
+func (m *Master) Contract(underlying string, expiry time.Time, strike float64, kind string) (Instrument, bool) {
+	i, ok := m.byContract[newContractKey(underlying, expiry, strike, strings.ToUpper(kind))]
+	if !ok {
+		return Instrument{}, false
+	}
+	return m.instruments[i], true
+}
+
+func (m *Master) Expiries(underlying string) []time.Time {
+	return m.expiries[strings.ToUpper(underlying)]
+}
+
+func (m *Master) Strikes(underlying string, expiry time.Time) []float64 {
+	key := newContractKey(underlying, expiry, 0, "")
+	return m.strikes[contractKey{underlying: key.underlying, expiry: key.expiry}]
+}
+
+func newContractKey(underlying string, expiry time.Time, strike float64, kind string) contractKey {
+	if kind == "FUT" {
+		strike = 0
+	}
+	return contractKey{
+		underlying: strings.ToUpper(underlying),
+		expiry:     expiry.Format("2006-01-02"),
+		strike:     int64(math.Round(strike * 100)),
+		kind:       kind,
+	}
+}
+
+func (r rawInstrument) parse() (Instrument, error) {
+	instrument := Instrument{
+		Token:          r.Token,
+		Symbol:         r.Symbol,
+		Name:           r.Name,
+		Exchange:       strings.ToUpper(r.ExchSeg),
+		InstrumentType: r.InstrumentType,
+	}
+	if r.Expiry != "" {
+		expiry, err := time.Parse(expiryLayout, r.Expiry)
+		if err != nil {
+			return Instrument{}, fmt.Errorf("parse expiry %q: %w", r.Expiry, err)
+		}
+		instrument.Expiry = expiry
+	}
+	strike, err := parseNumber(r.Strike)
+	if err != nil {
+		return Instrument{}, fmt.Errorf("parse strike %q: %w", r.Strike, err)
+	}
+	if strike > 0 {
+		instrument.Strike = strike / 100
+	}
+	lotSize, err := parseNumber(r.LotSize)
+	if err != nil {
+		return Instrument{}, fmt.Errorf("parse lot size %q: %w", r.LotSize, err)
+	}
+	instrument.LotSize = int64(lotSize)
+	tickSize, err := parseNumber(r.TickSize)
+	if err != nil {
+		return Instrument{}, fmt.Errorf("parse tick size %q: %w", r.TickSize, err)
+	}
+	instrument.TickSize = tickSize / 100
+	return instrument, nil
+}
+
+func parseNumber(raw string) (float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(raw, 64)
+}
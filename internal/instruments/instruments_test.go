@@ -0,0 +1,110 @@
+package instruments
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fixture = "testdata/scrip_master.json"
+
+func TestLoadParsesFixture(t *testing.T) {
+	master, err := Load(fixture)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if master.Len() != 27 {
+		t.Fatalf("Len() = %d, want 27", master.Len())
+	}
+
+	option, ok := master.ByToken("NFO", "35005")
+	if !ok {
+		t.Fatal("expected option by token")
+	}
+	want := Instrument{
+		Token:          "35005",
+		Symbol:         "NIFTY28NOV2424500CE",
+		Name:           "NIFTY",
+		Expiry:         time.Date(2024, 11, 28, 0, 0, 0, 0, time.UTC),
+		Strike:         24500,
+		LotSize:        25,
+		TickSize:       0.05,
+		Exchange:       "NFO",
+		InstrumentType: "OPTIDX",
+	}
+	if option != want {
+		t.Fatalf("ByToken() = %+v, want %+v", option, want)
+	}
+	if option.OptionType() != "CE" {
+		t.Fatalf("OptionType() = %q", option.OptionType())
+	}
+
+	equity, ok := master.BySymbol("nse", "reliance-eq")
+	if !ok || equity.Token != "2885" || equity.Strike != 0 || equity.OptionType() != "" {
+		t.Fatalf("BySymbol() = %+v, %v", equity, ok)
+	}
+	if bse, ok := master.BySymbol("BSE", "RELIANCE"); !ok || bse.Token != "500325" {
+		t.Fatalf("expected BSE lookup to be separate, got %+v", bse)
+	}
+	if _, ok := master.BySymbol("NSE", "MISSING-EQ"); ok {
+		t.Fatal("expected unknown symbol lookup to fail")
+	}
+}
+
+func TestContractLookupAndExpiries(t *testing.T) {
+	master, err := Load(fixture)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	expiries := master.Expiries("nifty")
+	if len(expiries) != 2 || !expiries[0].Equal(time.Date(2024, 11, 28, 0, 0, 0, 0, time.UTC)) || !expiries[1].Equal(time.Date(2024, 12, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expiries() = %v", expiries)
+	}
+	strikes := master.Strikes("NIFTY", expiries[0])
+	if len(strikes) != 5 || strikes[0] != 24300 || strikes[4] != 24700 {
+		t.Fatalf("Strikes() = %v", strikes)
+	}
+
+	put, ok := master.Contract("NIFTY", expiries[1], 24300, "pe")
+	if !ok || put.Symbol != "NIFTY05DEC2424300PE" {
+		t.Fatalf("Contract(PE) = %+v, %v", put, ok)
+	}
+	future, ok := master.Contract("NIFTY", expiries[0], 0, "FUT")
+	if !ok || future.Symbol != "NIFTY28NOV24FUT" {
+		t.Fatalf("Contract(FUT) = %+v, %v", future, ok)
+	}
+	if _, ok := master.Contract("NIFTY", expiries[0], 24550, "CE"); ok {
+		t.Fatal("expected missing strike lookup to fail")
+	}
+}
+
+func TestDownloadParsesResponse(t *testing.T) {
+	body, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	master, err := Download(context.Background(), srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if master.Len() != 27 {
+		t.Fatalf("Len() = %d", master.Len())
+	}
+}
+
+func TestParseRejectsBadEntries(t *testing.T) {
+	_, err := Parse(strings.NewReader(`[{"token":"1","symbol":"X","expiry":"31FOO2024","exch_seg":"NFO"}]`))
+	if err == nil || !strings.Contains(err.Error(), "token 1") {
+		t.Fatalf("expected expiry error, got %v", err)
+	}
+}
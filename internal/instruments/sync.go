@@ -0,0 +1,120 @@
+package instruments
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+type Store interface {
+	ReplaceInstruments(ctx context.Context, list []Instrument) error
+	LoadInstruments(ctx context.Context) ([]Instrument, error)
+}
+
+type SyncOptions struct {
+	Fetch    func(ctx context.Context) (*Master, error)
+	Store    Store
+	Enricher *Enricher
+	Location *time.Location
+	At       time.Duration
+	Logger   *log.Logger
+}
+
+type Syncer struct {
+	opts  SyncOptions
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func NewSyncer(opts SyncOptions) *Syncer {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	return &Syncer{opts: opts, now: time.Now, sleep: sleepContext}
+}
+
+func (s *Syncer) Sync(ctx context.Context) (*Master, error) {
+	master, err := s.opts.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if master.Len() == 0 {
+		return nil, fmt.Errorf("scrip master is empty")
+	}
+	if s.opts.Store != nil {
+		if err := s.opts.Store.ReplaceInstruments(ctx, master.All()); err != nil {
+			return nil, fmt.Errorf("store instruments: %w", err)
+		}
+	}
+	if s.opts.Enricher != nil {
+		s.opts.Enricher.SetMaster(master)
+	}
+	s.logf("synced %d instruments", master.Len())
+	return master, nil
+}
+
+func (s *Syncer) Bootstrap(ctx context.Context) (*Master, error) {
+	master, err := s.Sync(ctx)
+	if err == nil {
+		return master, nil
+	}
+	if s.opts.Store == nil {
+		return nil, err
+	}
+	list, loadErr := s.opts.Store.LoadInstruments(ctx)
+	if loadErr != nil {
+		return nil, fmt.Errorf("%w; load stored instruments: %v", err, loadErr)
+	}
+	if len(list) == 0 {
+		return nil, err
+	}
+	s.logf("instrument sync failed, using %d stored instruments: %v", len(list), err)
+	master = New(list)
+	if s.opts.Enricher != nil {
+		s.opts.Enricher.SetMaster(master)
+	}
+	return master, nil
+}
+
+func (s *Syncer) Run(ctx context.Context) error {
+	for {
+		next := nextRun(s.now(), s.opts.At, s.opts.Location)
+		if err := s.sleep(ctx, next.Sub(s.now())); err != nil {
+			return nil
+		}
+		if _, err := s.Sync(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			s.logf("instrument sync failed: %v", err)
+		}
+	}
+}
+
+func nextRun(now time.Time, at time.Duration, location *time.Location) time.Time {
+	local := now.In(location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	next := midnight.Add(at)
+	if !next.After(now) {
+		next = midnight.AddDate(0, 0, 1).Add(at)
+	}
+	return next
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (s *Syncer) logf(format string, args ...any) {
+	if s.opts.Logger != nil {
+		s.opts.Logger.Printf(format, args...)
+	}
+}
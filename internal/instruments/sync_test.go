@@ -0,0 +1,91 @@
+package instruments
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	list     []Instrument
+	replaces int
+}
+
+func (s *memoryStore) ReplaceInstruments(_ context.Context, list []Instrument) error {
+	s.replaces++
+	s.list = append([]Instrument(nil), list...)
+	return nil
+}
+
+func (s *memoryStore) LoadInstruments(context.Context) ([]Instrument, error) {
+	return s.list, nil
+}
+
+func TestSyncStoresMasterAndSwapsEnricher(t *testing.T) {
+	store := &memoryStore{}
+	enricher := NewEnricher(nil)
+	syncer := NewSyncer(SyncOptions{
+		Fetch:    func(context.Context) (*Master, error) { return Load(fixture) },
+		Store:    store,
+		Enricher: enricher,
+	})
+
+	master, err := syncer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if store.replaces != 1 || len(store.list) != master.Len() {
+		t.Fatalf("expected %d stored instruments, got %d", master.Len(), len(store.list))
+	}
+	if enricher.Master() != master {
+		t.Fatal("expected enricher to use the synced master")
+	}
+}
+
+func TestBootstrapFallsBackToStoredInstruments(t *testing.T) {
+	stored, err := Load(fixture)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	store := &memoryStore{list: stored.All()}
+	enricher := NewEnricher(nil)
+	syncer := NewSyncer(SyncOptions{
+		Fetch:    func(context.Context) (*Master, error) { return nil, errors.New("offline") },
+		Store:    store,
+		Enricher: enricher,
+	})
+
+	master, err := syncer.Bootstrap(context.Background())
+	if err != nil {
+		t.Fatalf("Bootstrap() error = %v", err)
+	}
+	if master.Len() != stored.Len() || enricher.Master() != master {
+		t.Fatalf("expected stored master, got %d instruments", master.Len())
+	}
+	if _, ok := master.BySymbol("NSE", "reliance-eq"); !ok {
+		t.Fatal("expected stored master to be indexed")
+	}
+
+	empty := NewSyncer(SyncOptions{
+		Fetch: func(context.Context) (*Master, error) { return nil, errors.New("offline") },
+		Store: &memoryStore{},
+	})
+	if _, err := empty.Bootstrap(context.Background()); err == nil {
+		t.Fatal("expected error without stored instruments")
+	}
+}
+
+func TestNextRunIsDailyAtConfiguredTime(t *testing.T) {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	at := 8 * time.Hour
+
+	before := time.Date(2024, 11, 28, 7, 0, 0, 0, ist)
+	if got := nextRun(before, at, ist); !got.Equal(time.Date(2024, 11, 28, 8, 0, 0, 0, ist)) {
+		t.Fatalf("nextRun(before) = %s", got)
+	}
+	after := time.Date(2024, 11, 28, 8, 0, 0, 0, ist)
+	if got := nextRun(after, at, ist); !got.Equal(time.Date(2024, 11, 29, 8, 0, 0, 0, ist)) {
+		t.Fatalf("nextRun(after) = %s", got)
+	}
+}
@@ -0,0 +1,95 @@
+package smartapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	apiKey     string
+	httpClient *http.Client
+}
+
+type envelope struct {
+	Status    bool            `json:"status"`
+	Message   string          `json:"message"`
+	ErrorCode string          `json:"errorcode"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func NewClient(apiKey string) *Client {
+	return &Client{
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.httpClient = client
+}
+
+func (c *Client) Post(ctx context.Context, url, authorization string, payload, data any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	c.setHeaders(req, authorization)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	var result envelope
+	if err := json.Unmarshal(raw, &result); err != nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+		}
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if !result.Status || resp.StatusCode >= http.StatusBadRequest {
+		return &Error{StatusCode: resp.StatusCode, Code: result.ErrorCode, Message: result.Message}
+	}
+	if data == nil || len(result.Data) == 0 || string(result.Data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(result.Data, data); err != nil {
+		return fmt.Errorf("decode response data: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) setHeaders(req *http.Request, authorization string) {
+	SetHeaders(req, c.apiKey, authorization)
+}
+
+func SetHeaders(req *http.Request, apiKey, authorization string) {
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-UserType", "USER")
+	req.Header.Set("X-SourceID", "WEB")
+	req.Header.Set("X-ClientLocalIP", "127.0.0.1")
+	req.Header.Set("X-ClientPublicIP", "127.0.0.1")
+	req.Header.Set("X-MACAddress", "00:00:00:00:00:00")
+	req.Header.Set("X-PrivateKey", apiKey)
+}
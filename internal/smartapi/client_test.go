@@ -0,0 +1,121 @@
+package smartapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPostSetsHeadersAndDecodesData(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-PrivateKey") != "key" || r.Header.Get("Authorization") != "Bearer jwt" || r.Header.Get("X-UserType") != "USER" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["clientcode"] != "client" {
+			t.Errorf("unexpected body: %v", body)
+		}
+		_, _ = w.Write([]byte(`{"status":true,"message":"SUCCESS","errorcode":"","data":{"value":42}}`))
+	}))
+	defer srv.Close()
+
+	var data struct {
+		Value int `json:"value"`
+	}
+	err := NewClient("key").Post(context.Background(), srv.URL, "Bearer jwt", map[string]string{"clientcode": "client"}, &data)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if data.Value != 42 {
+		t.Fatalf("data = %+v", data)
+	}
+}
+
+func TestPostMapsErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		is           []error
+		isNot        []error
+		wantTypedErr bool
+	}{
+		{
+			name:         "expired token",
+			status:       http.StatusOK,
+			body:         `{"status":false,"message":"Token Expired","errorcode":"AG8002","data":null}`,
+			is:           []error{ErrTokenExpired, ErrUnauthorized},
+			isNot:        []error{ErrInvalidToken, ErrRateLimited},
+			wantTypedErr: true,
+		},
+		{
+			name:         "unauthorized status",
+			status:       http.StatusUnauthorized,
+			body:         `{"status":false,"message":"Invalid Token","errorcode":"AG8001"}`,
+			is:           []error{ErrInvalidToken, ErrUnauthorized},
+			wantTypedErr: true,
+		},
+		{
+			name:         "internal error",
+			status:       http.StatusOK,
+			body:         `{"status":false,"message":"Something Went Wrong, Please Try After Sometime","errorcode":"AB1004"}`,
+			is:           []error{ErrInternal},
+			isNot:        []error{ErrUnauthorized, ErrRateLimited},
+			wantTypedErr: true,
+		},
+		{
+			name:         "access rate",
+			status:       http.StatusForbidden,
+			body:         `Access denied because of exceeding access rate`,
+			is:           []error{ErrRateLimited},
+			isNot:        []error{ErrUnauthorized},
+			wantTypedErr: true,
+		},
+		{
+			name:         "too many requests",
+			status:       http.StatusTooManyRequests,
+			body:         ``,
+			is:           []error{ErrRateLimited},
+			wantTypedErr: true,
+		},
+		{
+			name:   "malformed success",
+			status: http.StatusOK,
+			body:   `not json`,
+			isNot:  []error{ErrUnauthorized, ErrRateLimited, ErrInternal},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			err := NewClient("key").Post(context.Background(), srv.URL, "", map[string]string{}, nil)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			var apiErr *Error
+			if errors.As(err, &apiErr) != tt.wantTypedErr {
+				t.Fatalf("errors.As(*Error) = %v, want %v for %v", !tt.wantTypedErr, tt.wantTypedErr, err)
+			}
+			for _, target := range tt.is {
+				if !errors.Is(err, target) {
+					t.Fatalf("expected %v to match %v", err, target)
+				}
+			}
+			for _, target := range tt.isNot {
+				if errors.Is(err, target) {
+					t.Fatalf("expected %v not to match %v", err, target)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package smartapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrUnauthorized        = errors.New("smartapi: unauthorized")
+	ErrRateLimited         = errors.New("smartapi: rate limit exceeded")
+	ErrInvalidToken        = errors.New("smartapi: invalid token")
+	ErrTokenExpired        = errors.New("smartapi: token expired")
+	ErrTokenMissing        = errors.New("smartapi: token missing")
+	ErrInvalidRefreshToken = errors.New("smartapi: invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("smartapi: refresh token expired")
+	ErrSessionExpired      = errors.New("smartapi: session expired")
+	ErrNotLoggedIn         = errors.New("smartapi: client not logged in")
+	ErrInvalidCredentials  = errors.New("smartapi: invalid credentials")
+	ErrSymbolNotFound      = errors.New("smartapi: symbol not found")
+	ErrInternal            = errors.New("smartapi: internal error")
+)
+
+var codeErrors = map[string]error{
+	"AG8001": ErrInvalidToken,
+	"AG8002": ErrTokenExpired,
+	"AG8003": ErrTokenMissing,
+	"AB8050": ErrInvalidRefreshToken,
+	"AB8051": ErrRefreshTokenExpired,
+	"AB1010": ErrSessionExpired,
+	"AB1011": ErrNotLoggedIn,
+	"AB1000": ErrInvalidCredentials,
+	"AB1001": ErrInvalidCredentials,
+	"AB1002": ErrInvalidCredentials,
+	"AB1050": ErrInvalidCredentials,
+	"AB1009": ErrSymbolNotFound,
+	"AB1018": ErrSymbolNotFound,
+	"AB1004": ErrInternal,
+	"AB2000": ErrInternal,
+	"AB2001": ErrInternal,
+}
+
+var authErrors = []error{
+	ErrInvalidToken,
+	ErrTokenExpired,
+	ErrTokenMissing,
+	ErrInvalidRefreshToken,
+	ErrRefreshTokenExpired,
+	ErrSessionExpired,
+	ErrNotLoggedIn,
+}
+
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("smartapi")
+	if e.Code != "" {
+		fmt.Fprintf(&b, " %s", e.Code)
+	}
+	if e.StatusCode != 0 && e.StatusCode != http.StatusOK {
+		fmt.Fprintf(&b, " (HTTP %d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	return b.String()
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.rateLimited()
+	case ErrUnauthorized:
+		return e.unauthorized()
+	}
+	sentinel, ok := codeErrors[e.Code]
+	return ok && sentinel == target
+}
+
+func (e *Error) rateLimited() bool {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	message := strings.ToLower(e.Message)
+	return strings.Contains(message, "exceeding access rate") || strings.Contains(message, "too many requests")
+}
+
+func (e *Error) unauthorized() bool {
+	if e.rateLimited() {
+		return false
+	}
+	if e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden {
+		return true
+	}
+	sentinel := codeErrors[e.Code]
+	for _, err := range authErrors {
+		if sentinel == err {
+			return true
+		}
+	}
+	return strings.HasPrefix(e.Code, "AG80")
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/e1/internal/instruments"
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) ReplaceInstruments(ctx context.Context, list []instruments.Instrument) error {
+	rows := make([][]any, 0, len(list))
+	for _, instrument := range list {
+		rows = append(rows, []any{
+			instrument.Exchange,
+			instrument.Token,
+			instrument.Symbol,
+			instrument.Name,
+			nullableTime(instrument.Expiry),
+			instrument.Strike,
+			instrument.LotSize,
+			instrument.TickSize,
+			instrument.InstrumentType,
+		})
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin instruments: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM instruments`); err != nil {
+		return fmt.Errorf("clear instruments: %w", err)
+	}
+	_, err = tx.CopyFrom(
+		ctx,
+		pgx.Identifier{"instruments"},
+		[]string{
+			"exchange",
+			"token",
+			"symbol",
+			"name",
+			"expiry",
+			"strike",
+			"lot_size",
+			"tick_size",
+			"instrument_type",
+		},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return fmt.Errorf("copy instruments: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Store) LoadInstruments(ctx context.Context) ([]instruments.Instrument, error) {
+	const query = `
+	SELECT exchange, token, symbol, name, expiry, strike, lot_size, tick_size, instrument_type
+	FROM instruments`
+
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query instruments: %w", err)
+	}
+	defer rows.Close()
+
+	var list []instruments.Instrument
+	for rows.Next() {
+		var instrument instruments.Instrument
+		var expiry *time.Time
+		if err := rows.Scan(
+			&instrument.Exchange,
+			&instrument.Token,
+			&instrument.Symbol,
+			&instrument.Name,
+			&expiry,
+			&instrument.Strike,
+			&instrument.LotSize,
+			&instrument.TickSize,
+			&instrument.InstrumentType,
+		); err != nil {
+			return nil, fmt.Errorf("scan instrument: %w", err)
+		}
+		if expiry != nil {
+			instrument.Expiry = expiry.UTC()
+		}
+		list = append(list, instrument)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read instruments: %w", err)
+	}
+	return list, nil
+}
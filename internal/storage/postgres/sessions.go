@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"example.com/e1/internal/auth"
+	"github.com/jackc/pgx/v5"
+)
+
+type SessionStore struct {
+	store  *Store
+	cipher *auth.SessionCipher
+}
+
+func (s *Store) Sessions(cipher *auth.SessionCipher) *SessionStore {
+	return &SessionStore{store: s, cipher: cipher}
+}
+
+func (s *SessionStore) Load(ctx context.Context, clientID string) (auth.Session, bool, error) {
+	var sealed []byte
+	err := s.store.pool.QueryRow(ctx, `SELECT sealed FROM sessions WHERE client_id = $1`, clientID).Scan(&sealed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return auth.Session{}, false, nil
+	}
+	if err != nil {
+		return auth.Session{}, false, fmt.Errorf("load session: %w", err)
+	}
+	session, err := s.cipher.Open(sealed)
+	if err != nil {
+		return auth.Session{}, false, fmt.Errorf("load session: %w", err)
+	}
+	if session.ClientID != clientID {
+		return auth.Session{}, false, nil
+	}
+	return session, true, nil
+}
+
+func (s *SessionStore) Save(ctx context.Context, session auth.Session) error {
+	const query = `
+	INSERT INTO sessions (client_id, sealed, expires_at, updated_at)
+	VALUES ($1, $2, $3, NOW())
+	ON CONFLICT (client_id) DO UPDATE SET
+		sealed = EXCLUDED.sealed,
+		expires_at = EXCLUDED.expires_at,
+		updated_at = NOW()`
+
+	sealed, err := s.cipher.Seal(session)
+	if err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	if _, err := s.store.pool.Exec(ctx, query, session.ClientID, sealed, session.ExpiresAt); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	return nil
+}
+
+func (s *SessionStore) Delete(ctx context.Context, clientID string) error {
+	if _, err := s.store.pool.Exec(ctx, `DELETE FROM sessions WHERE client_id = $1`, clientID); err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	return nil
+}
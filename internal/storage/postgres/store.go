@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"example.com/e1/internal/domain"
 	"github.com/jackc/pgx/v5"
@@ -54,6 +55,53 @@ func (s *Store) InitSchema(ctx context.Context) error {
 	);
 	CREATE INDEX IF NOT EXISTS idx_live_ticks_event_time ON live_ticks (event_time);
 	CREATE INDEX IF NOT EXISTS idx_live_ticks_token_event_time ON live_ticks (token, event_time DESC);
+	CREATE INDEX IF NOT EXISTS idx_live_ticks_received_at_id ON live_ticks (received_at, id);
+
+	ALTER TABLE live_ticks ADD COLUMN IF NOT EXISTS last_traded_time TIMESTAMPTZ;
+	ALTER TABLE live_ticks ADD COLUMN IF NOT EXISTS open_interest BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE live_ticks ADD COLUMN IF NOT EXISTS oi_change_pct DOUBLE PRECISION NOT NULL DEFAULT 0;
+	ALTER TABLE live_ticks ADD COLUMN IF NOT EXISTS sequence_number BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE live_ticks ADD COLUMN IF NOT EXISTS lot_size BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE live_ticks ADD COLUMN IF NOT EXISTS expiry DATE;
+	CREATE INDEX IF NOT EXISTS idx_live_ticks_symbol_event_time ON live_ticks (trading_symbol, event_time DESC);
+
+	CREATE TABLE IF NOT EXISTS live_depth (
+		id BIGSERIAL PRIMARY KEY,
+		source TEXT NOT NULL,
+		token TEXT NOT NULL,
+		exchange_type INT NOT NULL DEFAULT 0,
+		event_time TIMESTAMPTZ NOT NULL,
+		received_at TIMESTAMPTZ NOT NULL,
+		side TEXT NOT NULL,
+		level INT NOT NULL,
+		price DOUBLE PRECISION NOT NULL,
+		quantity BIGINT NOT NULL,
+		orders INT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_live_depth_token_event_time ON live_depth (token, event_time DESC);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		client_id TEXT PRIMARY KEY,
+		sealed BYTEA NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS instruments (
+		exchange TEXT NOT NULL,
+		token TEXT NOT NULL,
+		symbol TEXT NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		expiry DATE,
+		strike DOUBLE PRECISION NOT NULL DEFAULT 0,
+		lot_size BIGINT NOT NULL DEFAULT 0,
+		tick_size DOUBLE PRECISION NOT NULL DEFAULT 0,
+		instrument_type TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (exchange, token)
+	);
+	CREATE INDEX IF NOT EXISTS idx_instruments_symbol ON instruments (symbol);
 	`
 	_, err := s.pool.Exec(ctx, query)
 	return err
@@ -64,7 +112,12 @@ func (s *Store) WriteBatch(ctx context.Context, ticks []domain.Tick) error {
 		return nil
 	}
 	rows := make([][]any, 0, len(ticks))
+	var depthRows [][]any
 	for _, tick := range ticks {
+		depthRows = appendDepthRows(depthRows, tick)
+		if tick.DepthOnly {
+			continue
+		}
 		rows = append(rows, []any{
 			string(tick.Source),
 			tick.Token,
@@ -87,38 +140,210 @@ func (s *Store) WriteBatch(ctx context.Context, ticks []domain.Tick) error {
 			tick.LowerCircuit,
 			tick.High52Week,
 			tick.Low52Week,
+			nullableTime(tick.LastTradedTime),
+			tick.OpenInterest,
+			tick.OIChangePct,
+			tick.SequenceNumber,
+			tick.LotSize,
+			nullableTime(tick.Expiry),
 		})
 	}
 
-	_, err := s.pool.CopyFrom(
-		ctx,
-		pgx.Identifier{"live_ticks"},
-		[]string{
-			"source",
-			"token",
-			"exchange",
-			"exchange_type",
-			"trading_symbol",
-			"event_time",
-			"received_at",
-			"ltp",
-			"last_traded_qty",
-			"volume",
-			"open_price",
-			"high_price",
-			"low_price",
-			"close_price",
-			"total_buy_qty",
-			"total_sell_qty",
-			"avg_traded_price",
-			"upper_circuit",
-			"lower_circuit",
-			"high_52_week",
-			"low_52_week",
-		},
-		pgx.CopyFromRows(rows),
-	)
-	return err
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin batch: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if len(rows) > 0 {
+		_, err = tx.CopyFrom(
+			ctx,
+			pgx.Identifier{"live_ticks"},
+			[]string{
+				"source",
+				"token",
+				"exchange",
+				"exchange_type",
+				"trading_symbol",
+				"event_time",
+				"received_at",
+				"ltp",
+				"last_traded_qty",
+				"volume",
+				"open_price",
+				"high_price",
+				"low_price",
+				"close_price",
+				"total_buy_qty",
+				"total_sell_qty",
+				"avg_traded_price",
+				"upper_circuit",
+				"lower_circuit",
+				"high_52_week",
+				"low_52_week",
+				"last_traded_time",
+				"open_interest",
+				"oi_change_pct",
+				"sequence_number",
+				"lot_size",
+				"expiry",
+			},
+			pgx.CopyFromRows(rows),
+		)
+		if err != nil {
+			return fmt.Errorf("copy ticks: %w", err)
+		}
+	}
+
+	if len(depthRows) > 0 {
+		_, err = tx.CopyFrom(
+			ctx,
+			pgx.Identifier{"live_depth"},
+			[]string{
+				"source",
+				"token",
+				"exchange_type",
+				"event_time",
+				"received_at",
+				"side",
+				"level",
+				"price",
+				"quantity",
+				"orders",
+			},
+			pgx.CopyFromRows(depthRows),
+		)
+		if err != nil {
+			return fmt.Errorf("copy depth: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func appendDepthRows(rows [][]any, tick domain.Tick) [][]any {
+	if tick.Depth == nil {
+		return rows
+	}
+	appendSide := func(side string, levels []domain.DepthLevel) {
+		for i, level := range levels {
+			rows = append(rows, []any{
+				string(tick.Source),
+				tick.Token,
+				tick.ExchangeType,
+				tick.EventTime,
+				tick.ReceivedAt,
+				side,
+				i + 1,
+				level.Price,
+				level.Quantity,
+				level.Orders,
+			})
+		}
+	}
+	appendSide("bid", tick.Depth.Bids)
+	appendSide("ask", tick.Depth.Asks)
+	return rows
+}
+
+const replayPageSize = 5000
+
+func (s *Store) ReadTicks(ctx context.Context, from, to time.Time, fn func(domain.Tick) error) error {
+	var lastReceived time.Time
+	var lastID int64
+	for {
+		page, ids, err := s.readTickPage(ctx, from, to, lastReceived, lastID)
+		if err != nil {
+			return err
+		}
+		for _, tick := range page {
+			if err := fn(tick); err != nil {
+				return err
+			}
+		}
+		if len(page) < replayPageSize {
+			return nil
+		}
+		lastReceived = page[len(page)-1].ReceivedAt
+		lastID = ids[len(ids)-1]
+	}
+}
+
+func (s *Store) readTickPage(ctx context.Context, from, to, afterReceived time.Time, afterID int64) ([]domain.Tick, []int64, error) {
+	const query = `
+	SELECT id, source, token, exchange, exchange_type, trading_symbol, event_time, received_at,
+		ltp, last_traded_qty, volume, open_price, high_price, low_price, close_price,
+		total_buy_qty, total_sell_qty, avg_traded_price, upper_circuit, lower_circuit,
+		high_52_week, low_52_week, last_traded_time, open_interest, oi_change_pct, sequence_number
+	FROM live_ticks
+	WHERE received_at >= $1 AND received_at < $2 AND source <> $3
+		AND (received_at, id) > ($4, $5)
+	ORDER BY received_at, id
+	LIMIT $6
+	`
+	rows, err := s.pool.Query(ctx, query, from, to, string(domain.SourceReplay), afterReceived, afterID, replayPageSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query ticks: %w", err)
+	}
+	defer rows.Close()
+
+	ticks := make([]domain.Tick, 0, replayPageSize)
+	ids := make([]int64, 0, replayPageSize)
+	for rows.Next() {
+		var (
+			id             int64
+			tick           domain.Tick
+			source         string
+			lastTradedTime *time.Time
+		)
+		if err := rows.Scan(
+			&id,
+			&source,
+			&tick.Token,
+			&tick.Exchange,
+			&tick.ExchangeType,
+			&tick.TradingSymbol,
+			&tick.EventTime,
+			&tick.ReceivedAt,
+			&tick.LTP,
+			&tick.LastTradedQty,
+			&tick.Volume,
+			&tick.OpenPrice,
+			&tick.HighPrice,
+			&tick.LowPrice,
+			&tick.ClosePrice,
+			&tick.TotalBuyQty,
+			&tick.TotalSellQty,
+			&tick.AvgTradedPrice,
+			&tick.UpperCircuit,
+			&tick.LowerCircuit,
+			&tick.High52Week,
+			&tick.Low52Week,
+			&lastTradedTime,
+			&tick.OpenInterest,
+			&tick.OIChangePct,
+			&tick.SequenceNumber,
+		); err != nil {
+			return nil, nil, fmt.Errorf("scan tick: %w", err)
+		}
+		tick.Source = domain.Source(source)
+		if lastTradedTime != nil {
+			tick.LastTradedTime = *lastTradedTime
+		}
+		ticks = append(ticks, tick)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read ticks: %w", err)
+	}
+	return ticks, ids, nil
+}
+
+func nullableTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
 }
 
 func (s *Store) Close() {
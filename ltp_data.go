@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"strings"
+
+	"example.com/e1/internal/smartapi"
 )
 
 func getLtpData(apikey string, jwtToken string, exchange string, tradingsymbol string, symboltoken string) {
@@ -28,16 +30,7 @@ func getLtpData(apikey string, jwtToken string, exchange string, tradingsymbol s
 		return
 	}
 
-	// Add headers
-	req.Header.Add("Authorization", jwtToken)
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-UserType", "USER")
-	req.Header.Add("X-SourceID", "WEB")
-	req.Header.Add("X-ClientLocalIP", "127.0.0.1")      // Replace with actual if needed
-	req.Header.Add("X-ClientPublicIP", "198.168.0.1")   // Replace with actual if needed
-	req.Header.Add("X-MACAddress", "00:0a:95:9d:68:16") // Replace with actual if needed
-	req.Header.Add("X-PrivateKey", apikey)
+	smartapi.SetHeaders(req, apikey, jwtToken)
 
 	res, err := client.Do(req)
 	if err != nil {
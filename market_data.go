@@ -12,6 +12,8 @@ import (
 	"strconv"
 	"strings"
 	// "github.com/joho/godotenv"
+
+	"example.com/e1/internal/smartapi"
 )
 
 type mode string
@@ -58,16 +60,7 @@ func getMarketData(apikey string, jwtToken string, exchange ExchangeType, symbol
 		return
 	}
 
-	// Add headers
-	req.Header.Add("Authorization", jwtToken)
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-UserType", "USER")
-	req.Header.Add("X-SourceID", "WEB")
-	req.Header.Add("X-ClientLocalIP", "127.0.0.1")      // Replace with actual if needed
-	req.Header.Add("X-ClientPublicIP", "198.168.0.1")   // Replace with actual if needed
-	req.Header.Add("X-MACAddress", "00:0a:95:9d:68:16") // Replace with actual if needed
-	req.Header.Add("X-PrivateKey", apikey)
+	smartapi.SetHeaders(req, apikey, jwtToken)
 
 	res, err := client.Do(req)
 	if err != nil {
@@ -160,16 +153,7 @@ func getMarketDataofMore(apikey string, jwtToken string, exchangeTokens map[stri
 		return
 	}
 
-	// Add headers
-	req.Header.Add("Authorization", jwtToken)
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-UserType", "USER")
-	req.Header.Add("X-SourceID", "WEB")
-	req.Header.Add("X-ClientLocalIP", "127.0.0.1")      // Replace with actual if needed
-	req.Header.Add("X-ClientPublicIP", "198.168.0.1")   // Replace with actual if needed
-	req.Header.Add("X-MACAddress", "00:0a:95:9d:68:16") // Replace with actual if needed
-	req.Header.Add("X-PrivateKey", apikey)
+	smartapi.SetHeaders(req, apikey, jwtToken)
 
 	res, err := client.Do(req)
 	if err != nil {
@@ -225,16 +209,7 @@ func marketDatatoDB(apikey string, jwtToken string, exchange ExchangeType, symbo
 		return "", "", "", 0, err
 	}
 
-	// Add headers
-	req.Header.Add("Authorization", jwtToken)
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-UserType", "USER")
-	req.Header.Add("X-SourceID", "WEB")
-	req.Header.Add("X-ClientLocalIP", "127.0.0.1")      // Replace with actual if needed
-	req.Header.Add("X-ClientPublicIP", "198.168.0.1")   // Replace with actual if needed
-	req.Header.Add("X-MACAddress", "00:0a:95:9d:68:16") // Replace with actual if needed
-	req.Header.Add("X-PrivateKey", apikey)
+	smartapi.SetHeaders(req, apikey, jwtToken)
 
 	res, err := client.Do(req)
 	if err != nil {
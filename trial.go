@@ -5,6 +5,8 @@ package main
 import (
 	"fmt"
 	"net/http"
+
+	"example.com/e1/internal/smartapi"
 )
 
 // Making a simple http request to demonstrate the use of net/http package
@@ -18,17 +20,14 @@ func get(url string) (*http.Response, error) {
 	return resp, nil
 }
 
-// making http request with headers
-func getWithHeaders(url string, headers map[string]string) (*http.Response, error) {
+// making http request with the SmartAPI headers
+func getWithHeaders(url, apiKey, authorization string) (*http.Response, error) {
 	client := &http.Client{}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-
-	for key, value := range headers {
-		req.Header.Add(key, value)
-	}
+	smartapi.SetHeaders(req, apiKey, authorization)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -43,18 +42,7 @@ func trial(){
 	// resp, err := get(url)
 	// fmt.Println(resp, err)
 
-	headers := map[string]string{
-		"Content-Type":     "application/json",
-		"X-ClientLocalIP":  "CLIENT_LOCAL_IP",
-		"X-ClientPublicIP": "CLIENT_PUBLIC_IP",
-		"X-MACAddress":     "MAC_ADDRESS",
-		"Accept":           "application/json",
-		"X-PrivateKey":     "api_key",
-		"X-UserType":       "USER",
-		"X-SourceID":       "WEB",
-		"Authorization":    "Bearer <JWT_TOKEN>",
-	}
-	resp, err := getWithHeaders(url, headers)
+	resp, err := getWithHeaders(url, "api_key", "Bearer <JWT_TOKEN>")
 	fmt.Println(resp, err)
 
 }